@@ -0,0 +1,148 @@
+package keychain
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const defaultConcurrency = 4
+
+// BatchError holds the error of every domain that failed in a batch retrieval.
+type BatchError map[string]error
+
+func (e BatchError) Error() string {
+	s := make([]string, 0, len(e))
+	for domain, err := range e {
+		s = append(s, fmt.Sprintf("%s: %v", domain, err))
+	}
+
+	sort.Strings(s)
+
+	return fmt.Sprintf("%s%v domains failed: %s", kErr, len(e), strings.Join(s, "; "))
+}
+
+func (e BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e))
+	for _, err := range e {
+		errs = append(errs, err)
+	}
+
+	return errs
+}
+
+// RetrieveMany retrieves each of domains and merges the results into one Map.
+// apw only accepts a single domain, so up to Client.Concurrency are retrieved
+// concurrently. Failed domains don't abort the batch, and are reported in a BatchError.
+func (c *Client) RetrieveMany(domains []string) (Map, error) {
+	m, errs := c.RetrieveAllConcurrent(context.Background(), domains, c.concurrency())
+	if len(errs) > 0 {
+		return m, BatchError(errs)
+	}
+
+	return m, nil
+}
+
+// RetrieveEach retrieves each of domains with up to Client.Concurrency apw calls
+// running at once, returning the Query or error of every domain. Once ctx is
+// done no more calls are started, and the remaining domains fail with its error.
+func (c *Client) RetrieveEach(ctx context.Context, domains []string) (map[string]*Query, map[string]error) {
+	queries := make(map[string]*Query)
+	errs := make(map[string]error)
+
+	var mu sync.Mutex
+	c.retrieveEach(ctx, domains, c.concurrency(), func(domain string, k *Query, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err != nil {
+			errs[domain] = err
+		} else {
+			queries[domain] = k
+		}
+	})
+
+	return queries, errs
+}
+
+func (c *Client) concurrency() int {
+	if c.Concurrency <= 0 {
+		return defaultConcurrency
+	}
+
+	return c.Concurrency
+}
+
+// RetrieveAllConcurrent retrieves each of domains with up to workers apw calls
+// running at once, or runtime.NumCPU() if workers <= 0. Successful results are
+// merged into one Map, failures are keyed by domain in the returned errors.
+func (c *Client) RetrieveAllConcurrent(ctx context.Context, domains []string, workers int) (Map, map[string]error) {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	m := make(Map)
+	errs := make(map[string]error)
+
+	var mu sync.Mutex
+	c.retrieveEach(ctx, domains, workers, func(domain string, k *Query, err error) {
+		var km Map
+		if err == nil {
+			km, err = k.Map()
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err != nil {
+			errs[domain] = err
+			return
+		}
+
+		for d, a := range km {
+			m[d] = append(m[d], a...)
+		}
+	})
+
+	return m, errs
+}
+
+// retrieveEach retrieves each unique domain with at most workers running at once,
+// calling fn concurrently with each result. Domains not started before ctx is done
+// are passed the context error.
+func (c *Client) retrieveEach(ctx context.Context, domains []string, workers int, fn func(domain string, k *Query, err error)) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, workers)
+	seen := make(map[string]bool, len(domains))
+
+	for _, domain := range domains {
+		if seen[domain] {
+			continue
+		}
+
+		seen[domain] = true
+
+		select {
+		case <-ctx.Done():
+			fn(domain, nil, fmt.Errorf("%s%w", kErr, ctx.Err()))
+			continue
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(domain string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			k, err := c.RetrieveContext(ctx, domain)
+			fn(domain, k, err)
+		}(domain)
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,61 @@
+package keychain
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const envAPW = "APW_PATH"
+
+var (
+	ErrUnsupportedPlatform = errors.New(kErr + "apw is only available on macOS")
+	ErrBinaryNotFound      = errors.New(kErr + "apw binary not found or not executable (install apw, e.g. with Homebrew, or set $APW_PATH or Client.BinaryPath)")
+
+	// Homebrew locations on Apple Silicon and Intel respectively
+	knownPathsAPW = []string{"/opt/homebrew/bin/apw", "/usr/local/bin/apw"}
+)
+
+// DetectBinary finds apw by checking $APW_PATH, then $PATH, then the known
+// Homebrew locations. If none of them exist, it returns ErrBinaryNotFound
+// listing the locations tried.
+func DetectBinary() (string, error) {
+	if p := os.Getenv(envAPW); len(p) > 0 {
+		return p, nil
+	}
+
+	if p, err := exec.LookPath("apw"); err == nil {
+		return p, nil
+	}
+
+	for _, p := range knownPathsAPW {
+		if fi, err := os.Stat(p); err == nil && !fi.IsDir() {
+			return p, nil
+		}
+	}
+
+	tried := append([]string{"$" + envAPW, "$PATH"}, knownPathsAPW...)
+	return "", fmt.Errorf("%w, tried %s", ErrBinaryNotFound, strings.Join(tried, ", "))
+}
+
+// LocateBinary is DetectBinary.
+//
+// Deprecated: Use DetectBinary.
+func LocateBinary() (string, error) {
+	return DetectBinary()
+}
+
+// IsBinaryNotFound reports whether err is caused by apw missing or not being executable.
+func IsBinaryNotFound(err error) bool {
+	return errors.Is(err, ErrBinaryNotFound)
+}
+
+func defaultPathAPW() string {
+	if p, err := DetectBinary(); err == nil {
+		return p
+	}
+
+	return knownPathsAPW[0]
+}
@@ -0,0 +1,78 @@
+package keychain
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBinaryNotFound(t *testing.T) {
+	c := &Client{BinaryPath: filepath.Join(t.TempDir(), "apw")}
+	c.Runner = runnerFunc(func(ctx context.Context, args ...string) ([]byte, error) {
+		return execRunner{path: c.BinaryPath, max: -1}.run(ctx, args...) // execRunner.Run is macOS only
+	})
+
+	_, err := c.Retrieve("example.com")
+	if !IsBinaryNotFound(err) {
+		t.Fatalf("got %v, want ErrBinaryNotFound", err)
+	}
+
+	if !strings.Contains(err.Error(), c.BinaryPath) {
+		t.Errorf("error %q doesn't name %s", err, c.BinaryPath)
+	}
+}
+
+func TestDetectBinary(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(envAPW, "")
+	t.Setenv("PATH", dir)
+
+	known := knownPathsAPW
+	knownPathsAPW = []string{filepath.Join(dir, "missing")}
+	t.Cleanup(func() { knownPathsAPW = known })
+
+	_, err := DetectBinary()
+	if !IsBinaryNotFound(err) || !strings.Contains(err.Error(), "tried $APW_PATH, $PATH, "+knownPathsAPW[0]) {
+		t.Fatalf("got %v, want ErrBinaryNotFound listing the locations tried", err)
+	}
+
+	c := NewClient("")
+	if cErr := c.Check(); cErr == nil || cErr.Error() != err.Error() {
+		t.Errorf("Check: got %v, want %v", cErr, err)
+	}
+
+	t.Setenv(envAPW, "/custom/apw")
+	if p, err := DetectBinary(); p != "/custom/apw" || err != nil {
+		t.Errorf("with $%s: got %q, %v", envAPW, p, err)
+	}
+}
+
+func TestEnvBinaryPath(t *testing.T) {
+	const shim = "/ci/bin/apw-shim"
+	t.Setenv(envAPW, shim)
+
+	tests := []struct {
+		name   string
+		client *Client
+		want   string
+	}{
+		{"zero Client", &Client{}, shim},
+		{"NewClient", NewClient(""), shim},
+		{"BinaryPath", &Client{BinaryPath: "/usr/bin/apw"}, "/usr/bin/apw"},
+		{"NewClient with path", NewClient("/usr/bin/apw"), "/usr/bin/apw"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if p := tt.client.runner(context.Background()).(execRunner).path; p != tt.want {
+				t.Errorf("got %q, want %q", p, tt.want)
+			}
+		})
+	}
+
+	t.Setenv(envAPW, "")
+	if p := (&Client{}).path(); p != PathAPW {
+		t.Errorf("without $%s: got %q, want PathAPW %q", envAPW, p, PathAPW)
+	}
+}
@@ -0,0 +1,101 @@
+package keychain
+
+import (
+	"sync"
+	"time"
+)
+
+type cacheEntry struct {
+	cmd     string
+	query   Query
+	fetched time.Time
+}
+
+type cache struct {
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+// clone returns a deep copy of k, see Account.clone.
+func (k Query) clone() *Query {
+	r := make([]Result, len(k.Results))
+	for i, d := range k.Results {
+		r[i] = Result{d.Account.clone(), d.Domain}
+	}
+
+	k.Results = r
+	return &k
+}
+
+func (k cacheEntry) zero() {
+	for i := range k.query.Results {
+		k.query.Results[i].Zero()
+	}
+}
+
+func (c *Client) cacheGet(cmd, domain string) (*Query, bool) {
+	if c.CacheTTL <= 0 {
+		return nil, false
+	}
+
+	c.cache.mu.Lock()
+	defer c.cache.mu.Unlock()
+
+	e, ok := c.cache.entries[domain]
+	if !ok || e.cmd != cmd {
+		return nil, false
+	}
+
+	if time.Since(e.fetched) >= c.CacheTTL {
+		e.zero()
+		delete(c.cache.entries, domain)
+		return nil, false
+	}
+
+	return e.query.clone(), true
+}
+
+func (c *Client) cachePut(cmd, domain string, k *Query) {
+	if c.CacheTTL <= 0 {
+		return
+	}
+
+	c.cache.mu.Lock()
+	defer c.cache.mu.Unlock()
+
+	if c.cache.entries == nil {
+		c.cache.entries = make(map[string]cacheEntry)
+	}
+
+	for d, e := range c.cache.entries { // Don't keep expired passwords around until their next lookup
+		if d == domain || time.Since(e.fetched) >= c.CacheTTL {
+			e.zero()
+			delete(c.cache.entries, d)
+		}
+	}
+
+	c.cache.entries[domain] = cacheEntry{cmd: cmd, query: *k.clone(), fetched: time.Now()}
+}
+
+// InvalidateCache drops the cached result for domain, clearing its passwords.
+func (c *Client) InvalidateCache(domain string) {
+	c.cache.mu.Lock()
+	defer c.cache.mu.Unlock()
+
+	if e, ok := c.cache.entries[c.domain(domain)]; ok {
+		e.zero()
+		delete(c.cache.entries, c.domain(domain))
+	}
+}
+
+// ClearCache drops every cached result, clearing their passwords.
+func (c *Client) ClearCache() {
+	c.cache.mu.Lock()
+	defer c.cache.mu.Unlock()
+
+	for _, e := range c.cache.entries {
+		e.zero()
+	}
+
+	c.cache.entries = nil
+}
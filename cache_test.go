@@ -0,0 +1,37 @@
+package keychain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestCacheReturnsCopies(t *testing.T) {
+	r := &fakeRunner{out: `{"results":[{"domain":"example.com","username":"alice","password":"s3cret",
+		"created":"2024-01-02T03:04:05Z","color":"blue"}],"status":0}`}
+	c := &Client{Runner: r, CacheTTL: time.Minute}
+
+	k, err := c.Retrieve("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := &k.Results[0].Account
+	a.Zero()
+	a.Extra["color"] = json.RawMessage(`"red"`)
+	*a.Created = time.Time{}
+
+	k, err = c.Retrieve("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a = &k.Results[0].Account
+	if a.Password != "s3cret" || string(a.Extra["color"]) != `"blue"` || a.Created.Year() != 2024 {
+		t.Errorf("cached account was modified through a returned copy: %+v", a)
+	}
+
+	if n := len(r.commands()); n != 1 {
+		t.Errorf("got %v apw calls, want 1", n)
+	}
+}
@@ -0,0 +1,797 @@
+package keychain
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Client talks to a single apw binary. The zero value uses $APW_PATH if set,
+// otherwise PathAPW.
+type Client struct {
+	BinaryPath string
+	Runner     Runner        // Executes apw, nil runs BinaryPath
+	Timeout    time.Duration // Limits each apw call, 0 for no limit
+
+	NormalizeDomains bool // Pass domains through NormalizeDomain before lookup
+	OmitPasswords    bool // Retrieve with "pw list", returning PasswordNotIncluded without prompting
+
+	Retries    int           // Extra attempts for calls failing with a retryable status, see IsRetryable
+	RetryDelay time.Duration // Wait before the first retry, doubling with each further one
+
+	Concurrency int // Maximum apw calls run at once by batch retrievals, 0 for 4
+
+	// MaxOutputBytes limits how much output is read from apw before the call
+	// fails with ErrOutputTooLarge, 0 for 16 MiB and negative for no limit.
+	MaxOutputBytes int
+
+	// Logger receives debug events for each apw call and parse failure, nil
+	// disables logging. Passwords are never logged.
+	Logger *slog.Logger
+
+	// CallHook is called after each apw invocation with its arguments, how
+	// long it took and the error it returned, nil disables it. Passwords in
+	// args are replaced by PasswordRedacted.
+	CallHook func(ctx context.Context, args []string, d time.Duration, err error)
+
+	// Observer is told about each operation, such as a retrieval, once it
+	// finishes, including any retries. nil disables it.
+	Observer Observer
+
+	// CacheTTL is how long Retrieve results are kept in memory and reused, 0
+	// disables caching. Cached passwords are cleared once expired, and by
+	// InvalidateCache and ClearCache. Callers receive copies, so calling Zero
+	// on a returned account doesn't affect the cache and vice versa.
+	CacheTTL time.Duration
+	cache    cache
+
+	detectErr error // Why NewClient couldn't find apw, see Check
+}
+
+// Runner executes apw with args and returns its output. Output should be
+// returned even when err is non-nil, as callAPW prefers parsing it over err.
+type Runner interface {
+	Run(ctx context.Context, args ...string) ([]byte, error)
+}
+
+var _ Runner = execRunner{}
+
+type execRunner struct {
+	path string
+	max  int // Maximum bytes read from each of stdout and stderr, negative for no limit
+}
+
+const defaultMaxOutputBytes = 16 << 20
+
+// ErrOutputTooLarge is returned when apw prints more than Client.MaxOutputBytes.
+var ErrOutputTooLarge = errors.New(kErr + "apw output too large")
+
+// DefaultClient backs the package-level functions, which are shortcuts for
+// calling its methods. It reads $APW_PATH and PathAPW on every call, so programs
+// that need different binaries concurrently should construct their own Client.
+var DefaultClient = &Client{}
+
+// NewClient returns a Client for the apw binary at path. An empty path is
+// resolved with DetectBinary, falling back to $APW_PATH or PathAPW at call
+// time if that fails. Check reports whether apw was found.
+func NewClient(path string) *Client {
+	if len(path) > 0 {
+		return &Client{BinaryPath: path}
+	}
+
+	path, err := DetectBinary()
+	return &Client{BinaryPath: path, detectErr: err}
+}
+
+// Check returns an error if c can't run apw, such as the one from DetectBinary
+// listing the locations NewClient tried. It doesn't run apw, see IsAvailable.
+func (c *Client) Check() error {
+	if c.Runner != nil {
+		return nil
+	}
+
+	if c.detectErr != nil && len(c.BinaryPath) == 0 && len(os.Getenv(envAPW)) == 0 {
+		return c.detectErr
+	}
+
+	if fi, err := os.Stat(c.path()); err != nil || fi.IsDir() {
+		return fmt.Errorf("%w: %s", ErrBinaryNotFound, c.path())
+	}
+
+	return nil
+}
+
+func (c *Client) path() string {
+	if len(c.BinaryPath) > 0 {
+		return c.BinaryPath
+	}
+
+	if p := os.Getenv(envAPW); len(p) > 0 {
+		return p
+	}
+
+	return PathAPW
+}
+
+func (c *Client) domain(domain string) string {
+	if c.NormalizeDomains {
+		return NormalizeDomain(domain)
+	}
+
+	return domain
+}
+
+func (c *Client) retrieveCmd(ctx context.Context) string {
+	if c.OmitPasswords || configFrom(ctx).omitPasswords {
+		return "list"
+	}
+
+	return "get"
+}
+
+func (c *Client) runner(ctx context.Context) Runner {
+	if c.Runner == nil {
+		if p := configFrom(ctx).binary; len(p) > 0 {
+			return execRunner{path: p, max: c.maxOutput()}
+		}
+
+		return execRunner{path: c.path(), max: c.maxOutput()}
+	}
+
+	return c.Runner
+}
+
+func (c *Client) Retrieve(domain string, opts ...Option) (*Query, error) {
+	return c.RetrieveContext(applyOptions(opts), domain)
+}
+
+func (c *Client) RetrieveContext(ctx context.Context, domain string) (*Query, error) {
+	return c.retrieve(ctx, c.retrieveCmd(ctx), domain)
+}
+
+// RetrieveRaw is like Retrieve, but also returns the JSON the Query was parsed
+// from, e.g. to read fields not modelled by Query. The JSON always includes the
+// plaintext passwords regardless of redaction, and is never cached.
+func (c *Client) RetrieveRaw(domain string) (json.RawMessage, *Query, error) {
+	return c.RetrieveRawContext(context.Background(), domain)
+}
+
+func (c *Client) RetrieveRawContext(ctx context.Context, domain string) (json.RawMessage, *Query, error) {
+	domain = c.domain(domain)
+	if err := validateArgs(domain); err != nil {
+		return nil, nil, err
+	}
+
+	out, k, err := c.callAPWRaw(ctx, "pw", c.retrieveCmd(ctx), domain)
+
+	var raw json.RawMessage
+	if decodeJSON(out, &raw) != nil { // Not JSON, e.g. a diagnostic
+		raw = out
+	}
+
+	return raw, k, err
+}
+
+// RetrieveMeta is like Retrieve, but never fetches passwords so apw doesn't
+// prompt for authorization. Every password is PasswordNotIncluded, for which
+// GetPassword returns ErrorPasswordNotIncluded.
+func (c *Client) RetrieveMeta(domain string) (*Query, error) {
+	return c.RetrieveMetaContext(context.Background(), domain)
+}
+
+func (c *Client) RetrieveMetaContext(ctx context.Context, domain string) (*Query, error) {
+	return c.retrieve(ctx, "list", domain)
+}
+
+func (c *Client) retrieve(ctx context.Context, cmd, domain string) (*Query, error) {
+	domain = c.domain(domain)
+	if err := validateArgs(domain); err != nil {
+		return nil, err
+	}
+
+	cacheable := len(configFrom(ctx).binary) == 0 // Cache only holds results from the Client's binary
+	if k, ok := c.cacheGet(cmd, domain); ok && cacheable {
+		return k, nil
+	}
+
+	k, err := c.callAPW(ctx, "pw", cmd, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	if k == nil {
+		return nil, ErrorDefault
+	}
+
+	if cacheable {
+		c.cachePut(cmd, domain, k)
+	}
+
+	return k, nil
+}
+
+// Domains returns the sorted domains of every stored password, or an empty
+// slice if nothing is stored.
+func (c *Client) Domains() ([]string, error) {
+	return c.DomainsContext(context.Background())
+}
+
+func (c *Client) DomainsContext(ctx context.Context) ([]string, error) {
+	km, err := c.RetrieveAllContext(ctx)
+	var qErr *QueryError
+	if errors.As(err, &qErr) && qErr.Status == StatusNoResults { // apw reports an empty keychain as an error
+		return []string{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return km.Domains(), nil
+}
+
+// ListDomains is Domains.
+func (c *Client) ListDomains() ([]string, error) {
+	return c.Domains()
+}
+
+func (c *Client) ListDomainsContext(ctx context.Context) ([]string, error) {
+	return c.DomainsContext(ctx)
+}
+
+// RetrieveAll returns every stored account. Passwords apw omits are kept as
+// PasswordNotIncluded, so callers can re-fetch those with Retrieve.
+func (c *Client) RetrieveAll() (Map, error) {
+	return c.RetrieveAllContext(context.Background())
+}
+
+func (c *Client) RetrieveAllContext(ctx context.Context) (Map, error) {
+	k, err := c.callAPW(ctx, "pw", "list")
+	if err != nil {
+		return nil, err
+	}
+
+	return k.Map()
+}
+
+// RetrieveAccount returns account on domain, see Map.Get for the errors returned.
+// ErrorDomain means there are no entries for domain at all, and ErrorAccount
+// means there are, but none for account; see IsDomainNotFound and IsAccountNotFound.
+// Entries apw matches to domain count as its own, even when they are stored
+// under another name, such as "example.com" for "login.example.com".
+func (c *Client) RetrieveAccount(domain, account string, opts ...Option) (*Account, error) {
+	return c.RetrieveAccountContext(applyOptions(opts), domain, account)
+}
+
+func (c *Client) RetrieveAccountContext(ctx context.Context, domain, account string) (*Account, error) {
+	if err := validateArgs(c.domain(domain), account); err != nil {
+		return nil, err
+	}
+
+	kq, err := c.RetrieveContext(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	km, err := kq.Map()
+	if err != nil {
+		return nil, err
+	}
+
+	return matchedDomain(km, c.domain(domain)).Get(c.domain(domain), account)
+}
+
+// matchedDomain returns km with the accounts apw returned for a lookup of
+// domain. apw may match domain to entries stored under another name, such as
+// its parent domain, in which case they are all filed under domain instead.
+func matchedDomain(km Map, domain string) Map {
+	if _, ok := km[domain]; ok || len(km) == 0 {
+		return km
+	}
+
+	return Map{domain: km.Accounts()}
+}
+
+// RetrieveAccounts returns every account stored for domain, or ErrorDomain if
+// there are none. Like RetrieveAccount, this includes the entries apw matches
+// to domain that are stored under another name.
+func (c *Client) RetrieveAccounts(domain string) ([]Account, error) {
+	return c.RetrieveAccountsContext(context.Background(), domain)
+}
+
+func (c *Client) RetrieveAccountsContext(ctx context.Context, domain string) ([]Account, error) {
+	kq, err := c.RetrieveContext(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	km, err := kq.Map()
+	if err != nil {
+		return nil, err
+	}
+
+	return matchedDomain(km, c.domain(domain)).GetAll(c.domain(domain))
+}
+
+// RetrieveAccountMatches is like RetrieveAccount, but returns every entry for
+// account on domain, so duplicate entries can be detected.
+func (c *Client) RetrieveAccountMatches(domain, account string) ([]*Account, error) {
+	return c.RetrieveAccountMatchesContext(context.Background(), domain, account)
+}
+
+func (c *Client) RetrieveAccountMatchesContext(ctx context.Context, domain, account string) ([]*Account, error) {
+	if err := validateArgs(c.domain(domain), account); err != nil {
+		return nil, err
+	}
+
+	kq, err := c.RetrieveContext(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	km, err := kq.Map()
+	if err != nil {
+		return nil, err
+	}
+
+	return matchedDomain(km, c.domain(domain)).GetMatches(c.domain(domain), account)
+}
+
+func (c *Client) callAPW(ctx context.Context, args ...string) (*Query, error) {
+	_, k, err := c.callAPWRaw(ctx, args...)
+	return k, err
+}
+
+// callAPWRaw is like callAPW, but also returns the output the Query was parsed from.
+func (c *Client) callAPWRaw(ctx context.Context, args ...string) (out []byte, k *Query, err error) {
+	if c.Observer != nil {
+		defer c.observe(args[1], time.Now(), &err)
+	}
+
+	err = c.retry(ctx, func() error {
+		out, k, err = c.callAPWOnce(ctx, args...)
+		return err
+	})
+
+	return out, k, err
+}
+
+// retry calls once until it succeeds, fails with an error that isn't
+// IsRetryable, or c.Retries is exhausted, returning its last error.
+func (c *Client) retry(ctx context.Context, once func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := once()
+		if attempt >= c.Retries || !IsRetryable(err) {
+			return err
+		}
+
+		delay := c.RetryDelay << attempt
+		if d, ok := ctx.Deadline(); ok && time.Until(d) < delay { // Retry could never finish in time
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s%w", kErr, ctx.Err())
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (c *Client) callAPWOnce(ctx context.Context, args ...string) ([]byte, *Query, error) {
+	var k Query
+	out, err := c.call(ctx, &k, args...)
+	if err != nil {
+		return out, nil, err
+	}
+
+	// Check for APW error in response
+	if err := k.Error(); err != nil {
+		return out, &k, err
+	}
+
+	return out, &k, nil
+}
+
+// call runs apw with args and unmarshals its output into v, returning the output.
+func (c *Client) call(ctx context.Context, v any, args ...string) ([]byte, error) {
+	out, err := c.Exec(ctx, args...)
+	if err != nil && len(out) == 0 { // Only return error message if we have no stdout
+		return out, err
+	}
+
+	if jErr := decodeJSON(out, v); jErr != nil {
+		c.logParse(ctx, args, out, jErr)
+		if err != nil { // Unparseable output of a failed command is most likely a diagnostic
+			return out, err
+		}
+
+		return out, jErr
+	}
+
+	return out, nil
+}
+
+// Exec runs apw with args passed verbatim, without a shell, and returns its raw
+// output, e.g. to use subcommands this package doesn't wrap yet. Parsing the
+// output is up to the caller. If apw exits non-zero, its output is returned
+// along with an ExecError. Every other call to apw goes through Exec.
+func (c *Client) Exec(ctx context.Context, args ...string) ([]byte, error) {
+	start := time.Now()
+	out, err := c.run(ctx, args...)
+	if err != nil {
+		err = execError(out, err)
+	}
+
+	d := time.Since(start)
+	c.logExec(ctx, args, d, err)
+	if c.CallHook != nil {
+		c.CallHook(ctx, logArgs(args), d, err)
+	}
+
+	return out, err
+}
+
+// validateArgs rejects a domain or accounts apw can't look up before spawning
+// it, including values apw would parse as a flag, such as "--help".
+func validateArgs(domain string, accounts ...string) error {
+	if !validArg(domain) {
+		return ErrorDomain
+	}
+
+	for _, a := range accounts {
+		if !validArg(a) {
+			return ErrorAccount
+		}
+	}
+
+	return nil
+}
+
+// validatePassword rejects an empty password, or one apw would parse as a flag.
+// These are rejected rather than relying on apw accepting "--" to end its flags.
+func validatePassword(p string) error {
+	if len(p) == 0 {
+		return ErrorPassword
+	}
+
+	if strings.HasPrefix(p, "-") {
+		return passwordError(`password can't start with "-"`)
+	}
+
+	return nil
+}
+
+// passwordError is an invalid password, which unwraps to ErrorPassword.
+type passwordError string
+
+func (e passwordError) Error() string {
+	return kErr + string(e)
+}
+
+func (e passwordError) Unwrap() error {
+	return ErrorPassword
+}
+
+func validArg(s string) bool {
+	return len(strings.TrimSpace(s)) > 0 && !strings.HasPrefix(s, "-")
+}
+
+// decodeJSON decodes the first JSON object in out into v, skipping a BOM and
+// any warnings apw printed around it.
+func decodeJSON(out []byte, v any) error {
+	b := bytes.TrimPrefix(out, []byte("\xef\xbb\xbf"))
+	if i := bytes.IndexByte(b, '{'); i > 0 {
+		b = b[i:]
+	}
+
+	if err := json.NewDecoder(bytes.NewReader(b)).Decode(v); err != nil {
+		if preview := outputPreview(out); len(preview) > 0 {
+			return fmt.Errorf("%sinvalid apw output (%v bytes, starting %q): %w", kErr, len(out), preview, err)
+		}
+
+		return fmt.Errorf("%sinvalid apw output (%v bytes): %w", kErr, len(out), err)
+	}
+
+	return nil
+}
+
+// outputPreview returns the start of the first line of out for diagnosing
+// unparseable output, or nothing if out may hold a password.
+func outputPreview(out []byte) []byte {
+	const maxPreview = 128
+	if bytes.Contains(out, []byte(`"password"`)) {
+		return nil
+	}
+
+	line, _, _ := bytes.Cut(bytes.TrimSpace(out), []byte("\n"))
+	if len(line) > maxPreview {
+		line = append(line[:maxPreview:maxPreview], "..."...)
+	}
+
+	return line
+}
+
+// run runs apw with args, returning its raw output and error.
+func (c *Client) run(ctx context.Context, args ...string) ([]byte, error) {
+	timeout := c.Timeout
+	if cfg := configFrom(ctx); cfg.timeout > 0 {
+		timeout = cfg.timeout
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	out, err := c.runner(ctx).Run(ctx, args...)
+	if IsBinaryNotFound(err) && len(configFrom(ctx).binary) == 0 {
+		if cErr := c.Check(); cErr != nil {
+			err = cErr // Names every location tried, if NewClient looked
+		}
+	}
+
+	if ctxErr := ctx.Err(); ctxErr != nil { // Process was killed, output is incomplete
+		return nil, fmt.Errorf("%s%w", kErr, ctxErr)
+	}
+
+	if limit := c.maxOutput(); errors.Is(err, ErrOutputTooLarge) || (limit >= 0 && len(out) > limit) { // Also covers custom Runners
+		return nil, ErrOutputTooLarge
+	}
+
+	return out, err
+}
+
+func (c *Client) maxOutput() int {
+	if c.MaxOutputBytes == 0 {
+		return defaultMaxOutputBytes
+	}
+
+	return c.MaxOutputBytes
+}
+
+// ExecError is returned when apw exits non-zero without a parseable response,
+// with the diagnostic apw printed to stderr.
+type ExecError struct {
+	ExitCode int
+	Stderr   string
+	Err      error
+	Kind     Error // Matched from Stderr, e.g. ErrorAuthCancelled
+}
+
+func (e *ExecError) Error() string {
+	if len(e.Stderr) == 0 {
+		return fmt.Sprintf("%sapw exited with %v: %v", kErr, e.ExitCode, e.Err)
+	}
+
+	return fmt.Sprintf("%sapw exited with %v: %s", kErr, e.ExitCode, e.Stderr)
+}
+
+func (e *ExecError) Unwrap() []error {
+	if e.Kind == ErrorDefault {
+		return []error{e.Err}
+	}
+
+	return []error{e.Err, e.Kind}
+}
+
+func execError(out []byte, err error) error {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return err
+	}
+
+	stderr := exitErr.Stderr
+	if len(stderr) == 0 && !bytes.Contains(out, []byte(`"password"`)) { // Runners may combine both streams
+		stderr = out
+	}
+
+	msg := strings.TrimSpace(string(stderr))
+	return &ExecError{ExitCode: exitErr.ExitCode(), Stderr: msg, Err: err, Kind: messageToError(msg)}
+}
+
+// Add stores a new password for username on domain and returns the stored entry.
+// Note that the password is passed to apw as a command-line argument, so one
+// starting with "-" is rejected with ErrorPassword.
+func (c *Client) Add(domain, username, password string) (*Result, error) {
+	return c.AddContext(context.Background(), domain, username, password)
+}
+
+func (c *Client) AddContext(ctx context.Context, domain, username, password string) (*Result, error) {
+	domain = c.domain(domain)
+	if err := validateArgs(domain, username); err != nil {
+		return nil, err
+	}
+
+	if err := validatePassword(password); err != nil {
+		return nil, err
+	}
+
+	k, err := c.callAPW(ctx, "pw", "add", domain, username, password)
+	c.InvalidateCache(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	if k == nil || len(k.Results) == 0 {
+		return nil, ErrorDefault
+	}
+
+	return &k.Results[0], nil
+}
+
+// AddAccount is like Add, but only reports whether it succeeded. It returns
+// ErrorDuplicate if username already has a password on domain.
+func (c *Client) AddAccount(domain, username, password string) error {
+	return c.AddAccountContext(context.Background(), domain, username, password)
+}
+
+func (c *Client) AddAccountContext(ctx context.Context, domain, username, password string) error {
+	_, err := c.AddContext(ctx, domain, username, password)
+	return err
+}
+
+// Delete removes the password for username on domain, returning ErrorDomain or
+// ErrorAccount when there is no such entry.
+func (c *Client) Delete(domain, username string) error {
+	return c.DeleteContext(context.Background(), domain, username)
+}
+
+func (c *Client) DeleteContext(ctx context.Context, domain, username string) error {
+	domain = c.domain(domain)
+	if err := validateArgs(domain, username); err != nil {
+		return err
+	}
+
+	if err := c.exists(ctx, domain, username); err != nil {
+		return err
+	}
+
+	_, err := c.callAPW(ctx, "pw", "delete", domain, username)
+	c.InvalidateCache(domain)
+	return err
+}
+
+// DeleteAccount is like Delete, but returns ErrorAccount whenever there is no
+// such entry, including when domain has no entries at all. Deleting is not
+// idempotent: a second call for the same entry fails this way.
+func (c *Client) DeleteAccount(domain, username string) error {
+	return c.DeleteAccountContext(context.Background(), domain, username)
+}
+
+func (c *Client) DeleteAccountContext(ctx context.Context, domain, username string) error {
+	err := c.DeleteContext(ctx, domain, username)
+	if errors.Is(err, ErrorDomain) && validArg(domain) {
+		return ErrorAccount
+	}
+
+	return err
+}
+
+// DeleteAll removes every password stored for domain, returning ErrorDomain if
+// there are none. Each account is deleted with its own apw call, and failures
+// don't stop the rest from being deleted; they are joined in the returned error.
+func (c *Client) DeleteAll(domain string) error {
+	return c.DeleteAllContext(context.Background(), domain)
+}
+
+func (c *Client) DeleteAllContext(ctx context.Context, domain string) error {
+	domain = c.domain(domain)
+	if err := validateArgs(domain); err != nil {
+		return err
+	}
+
+	kq, err := c.callAPW(ctx, "pw", "list", domain) // Only usernames are needed, as in exists
+	if err != nil {
+		return err
+	}
+
+	km, err := kq.Map()
+	if err != nil {
+		return err
+	}
+
+	accounts, ok := km[domain]
+	if !ok {
+		return ErrorDomain
+	}
+
+	var errs []error
+	for _, a := range accounts {
+		if _, err := c.callAPW(ctx, "pw", "delete", domain, a.Username); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", a.Username, err))
+		}
+	}
+
+	c.InvalidateCache(domain)
+	return errors.Join(errs...)
+}
+
+// exists returns ErrorDomain or ErrorAccount if there is no entry for username
+// on domain, or the error from looking it up. It uses "pw list", so apw doesn't
+// prompt or return passwords, and bypasses the cache, which may be stale.
+func (c *Client) exists(ctx context.Context, domain, username string) error {
+	kq, err := c.callAPW(ctx, "pw", "list", domain)
+	if err != nil {
+		return err
+	}
+
+	km, err := kq.Map()
+	if err != nil {
+		return err
+	}
+
+	if _, err := km.Get(domain, username); errors.Is(err, ErrorDomain) || errors.Is(err, ErrorAccount) {
+		return err
+	}
+
+	return nil
+}
+
+// Update replaces the password for username on domain with newPassword. The
+// replacement is a single apw invocation, so the entry is never missing: it
+// either keeps the old password or has the new one, depending on the result.
+func (c *Client) Update(domain, username, newPassword string) error {
+	return c.UpdateContext(context.Background(), domain, username, newPassword)
+}
+
+func (c *Client) UpdateContext(ctx context.Context, domain, username, newPassword string) error {
+	domain = c.domain(domain)
+	if err := validateArgs(domain, username); err != nil {
+		return err
+	}
+
+	if err := validatePassword(newPassword); err != nil {
+		return err
+	}
+
+	_, err := c.callAPW(ctx, "pw", "update", domain, username, newPassword)
+	c.InvalidateCache(domain)
+	return err
+}
+
+// UpdatePassword is like Update, but returns ErrorDomain or ErrorAccount when
+// there is no entry to update.
+func (c *Client) UpdatePassword(domain, username, newPassword string) error {
+	return c.UpdatePasswordContext(context.Background(), domain, username, newPassword)
+}
+
+func (c *Client) UpdatePasswordContext(ctx context.Context, domain, username, newPassword string) error {
+	domain = c.domain(domain)
+	if err := validateArgs(domain, username); err != nil {
+		return err
+	}
+
+	if err := validatePassword(newPassword); err != nil {
+		return err
+	}
+
+	if err := c.exists(ctx, domain, username); err != nil {
+		return err
+	}
+
+	return c.UpdateContext(ctx, domain, username, newPassword)
+}
+
+// UpsertPassword sets the password for username on domain, adding the entry
+// if it doesn't exist yet.
+func (c *Client) UpsertPassword(domain, username, password string) error {
+	return c.UpsertPasswordContext(context.Background(), domain, username, password)
+}
+
+func (c *Client) UpsertPasswordContext(ctx context.Context, domain, username, password string) error {
+	err := c.UpdatePasswordContext(ctx, domain, username, password)
+	if IsNotFound(err) {
+		return c.AddAccountContext(ctx, domain, username, password)
+	}
+
+	return err
+}
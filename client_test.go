@@ -0,0 +1,594 @@
+package keychain
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRunner records the arguments of each call and answers with fn, or with
+// out if fn is nil.
+type fakeRunner struct {
+	out string
+	fn  func(call int, args []string) ([]byte, error)
+
+	mu    sync.Mutex
+	calls [][]string
+}
+
+func (r *fakeRunner) Run(ctx context.Context, args ...string) ([]byte, error) {
+	r.mu.Lock()
+	r.calls = append(r.calls, args)
+	n := len(r.calls)
+	r.mu.Unlock()
+
+	if r.fn != nil {
+		return r.fn(n, args)
+	}
+
+	return []byte(r.out), nil
+}
+
+// commands returns each recorded call joined by spaces.
+func (r *fakeRunner) commands() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c := make([]string, len(r.calls))
+	for i, args := range r.calls {
+		c[i] = strings.Join(args, " ")
+	}
+
+	return c
+}
+
+// runnerFunc adapts a function to a Runner.
+type runnerFunc func(ctx context.Context, args ...string) ([]byte, error)
+
+func (f runnerFunc) Run(ctx context.Context, args ...string) ([]byte, error) {
+	return f(ctx, args...)
+}
+
+const exampleQuery = `{"results":[{"domain":"example.com","username":"alice","password":"s3cret"}],"status":0}`
+
+func TestRetrievePasswordModes(t *testing.T) {
+	tests := []struct {
+		name     string
+		omit     bool // Client.OmitPasswords
+		retrieve func(c *Client) error
+		want     string
+	}{
+		{"default", false, func(c *Client) error {
+			_, err := c.Retrieve("example.com")
+			return err
+		}, "pw get example.com"},
+		{"OmitPasswords", true, func(c *Client) error {
+			_, err := c.Retrieve("example.com")
+			return err
+		}, "pw list example.com"},
+		{"WithoutPasswords", false, func(c *Client) error {
+			_, err := c.Retrieve("example.com", WithoutPasswords())
+			return err
+		}, "pw list example.com"},
+		{"RetrieveMeta", false, func(c *Client) error {
+			_, err := c.RetrieveMeta("example.com")
+			return err
+		}, "pw list example.com"},
+		{"RetrieveAccount OmitPasswords", true, func(c *Client) error {
+			_, err := c.RetrieveAccount("example.com", "alice")
+			return err
+		}, "pw list example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &fakeRunner{out: exampleQuery}
+			c := &Client{Runner: r, OmitPasswords: tt.omit}
+			if err := tt.retrieve(c); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := r.commands(); len(got) != 1 || got[0] != tt.want {
+				t.Errorf("got calls %q, want [%q]", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthCancelled(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		err  error
+	}{
+		{"status", `{"results":[],"status":1,"error":"User cancelled the request"}`, nil},
+		{"stderr", "", &exec.ExitError{Stderr: []byte("Error: user cancelled authentication\n")}},
+		{"combined output", "Error: user cancelled authentication\n", &exec.ExitError{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &fakeRunner{fn: func(int, []string) ([]byte, error) { return []byte(tt.out), tt.err }}
+			c := &Client{Runner: r, Retries: 2}
+
+			_, err := c.Retrieve("example.com")
+			if !errors.Is(err, ErrorAuthCancelled) {
+				t.Errorf("got %v, want ErrorAuthCancelled", err)
+			}
+
+			if n := len(r.commands()); n != 1 {
+				t.Errorf("cancelled call was run %v times, want 1", n)
+			}
+		})
+	}
+}
+
+func TestContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &fakeRunner{fn: func(int, []string) ([]byte, error) {
+		cancel() // As if the caller gave up while apw was running
+		return nil, errors.New("signal: killed")
+	}}
+
+	c := &Client{Runner: r, Retries: 2}
+	if _, err := c.RetrieveContext(ctx, "example.com"); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+
+	if n := len(r.commands()); n != 1 {
+		t.Errorf("cancelled call was run %v times, want 1", n)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	const locked = `{"results":[],"status":9}`
+	tests := []struct {
+		name    string
+		retries int
+		delay   time.Duration
+		fails   int    // Calls answered with fail before succeeding
+		fail    string // apw output of a failed call
+		calls   int
+		want    error
+	}{
+		{"fail twice then succeed", 2, time.Millisecond, 2, locked, 3, nil},
+		{"out of retries", 1, time.Millisecond, 2, locked, 2, ErrorLocked},
+		{"no retries", 0, time.Millisecond, 2, locked, 1, ErrorLocked},
+		{"not found", 2, time.Millisecond, 2, `{"results":[],"status":3}`, 1, ErrorDomain},
+		{"delay past deadline", 2, time.Hour, 2, locked, 1, ErrorLocked},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &fakeRunner{fn: func(call int, _ []string) ([]byte, error) {
+				if call <= tt.fails {
+					return []byte(tt.fail), nil
+				}
+
+				return []byte(exampleQuery), nil
+			}}
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+
+			c := &Client{Runner: r, Retries: tt.retries, RetryDelay: tt.delay}
+			if _, err := c.RetrieveContext(ctx, "example.com"); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+
+			if n := len(r.commands()); n != tt.calls {
+				t.Errorf("apw was run %v times, want %v", n, tt.calls)
+			}
+		})
+	}
+}
+
+// listing returns a fakeRunner fn answering "pw list" with list and every other
+// command with exampleQuery.
+func listing(list string) func(int, []string) ([]byte, error) {
+	return func(_ int, args []string) ([]byte, error) {
+		if len(args) > 1 && args[1] == "list" {
+			return []byte(list), nil
+		}
+
+		return []byte(exampleQuery), nil
+	}
+}
+
+const (
+	noResults   = `{"results":[],"status":3}`
+	twoAccounts = `{"results":[{"domain":"example.com","username":"alice","password":"Not Included"},
+		{"domain":"example.com","username":"bob","password":"Not Included"}],"status":0}`
+)
+
+func TestUpdatePassword(t *testing.T) {
+	tests := []struct {
+		name   string
+		list   string
+		update func(c *Client) error
+		want   []string
+		err    error
+	}{
+		{"update", exampleQuery, func(c *Client) error {
+			return c.UpdatePassword("example.com", "alice", "n3w")
+		}, []string{"pw list example.com", "pw update example.com alice n3w"}, nil},
+		{"missing domain", noResults, func(c *Client) error {
+			return c.UpdatePassword("example.com", "alice", "n3w")
+		}, []string{"pw list example.com"}, ErrorDomain},
+		{"missing account", exampleQuery, func(c *Client) error {
+			return c.UpdatePassword("example.com", "bob", "n3w")
+		}, []string{"pw list example.com"}, ErrorAccount},
+		{"flag password", exampleQuery, func(c *Client) error {
+			return c.UpdatePassword("example.com", "alice", "--help")
+		}, nil, ErrorPassword},
+		{"empty password", exampleQuery, func(c *Client) error {
+			return c.UpdatePassword("example.com", "alice", "")
+		}, nil, ErrorPassword},
+		{"upsert existing", exampleQuery, func(c *Client) error {
+			return c.UpsertPassword("example.com", "alice", "n3w")
+		}, []string{"pw list example.com", "pw update example.com alice n3w"}, nil},
+		{"upsert missing account", exampleQuery, func(c *Client) error {
+			return c.UpsertPassword("example.com", "bob", "n3w")
+		}, []string{"pw list example.com", "pw add example.com bob n3w"}, nil},
+		{"upsert missing domain", noResults, func(c *Client) error {
+			return c.UpsertPassword("example.com", "bob", "n3w")
+		}, []string{"pw list example.com", "pw add example.com bob n3w"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &fakeRunner{fn: listing(tt.list)}
+			c := &Client{Runner: r}
+
+			if err := tt.update(c); !errors.Is(err, tt.err) {
+				t.Errorf("got %v, want %v", err, tt.err)
+			}
+
+			if got := r.commands(); !slices.Equal(got, tt.want) {
+				t.Errorf("got calls %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	tests := []struct {
+		name   string
+		list   string
+		delete func(c *Client) error
+		want   []string
+		err    error
+	}{
+		{"account", twoAccounts, func(c *Client) error {
+			return c.DeleteAccount("example.com", "bob")
+		}, []string{"pw list example.com", "pw delete example.com bob"}, nil},
+		{"missing account", twoAccounts, func(c *Client) error {
+			return c.DeleteAccount("example.com", "carol")
+		}, []string{"pw list example.com"}, ErrorAccount},
+		{"account of missing domain", noResults, func(c *Client) error {
+			return c.DeleteAccount("example.com", "alice")
+		}, []string{"pw list example.com"}, ErrorAccount},
+		{"Delete of missing domain", noResults, func(c *Client) error {
+			return c.Delete("example.com", "alice")
+		}, []string{"pw list example.com"}, ErrorDomain},
+		{"flag domain", twoAccounts, func(c *Client) error {
+			return c.DeleteAccount("-h", "alice")
+		}, nil, ErrorDomain},
+		{"all", twoAccounts, func(c *Client) error {
+			return c.DeleteAll("example.com")
+		}, []string{"pw list example.com", "pw delete example.com alice", "pw delete example.com bob"}, nil},
+		{"all of missing domain", noResults, func(c *Client) error {
+			return c.DeleteAll("example.com")
+		}, []string{"pw list example.com"}, ErrorDomain},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &fakeRunner{fn: listing(tt.list)}
+			c := &Client{Runner: r}
+
+			if err := tt.delete(c); !errors.Is(err, tt.err) {
+				t.Errorf("got %v, want %v", err, tt.err)
+			}
+
+			if got := r.commands(); !slices.Equal(got, tt.want) {
+				t.Errorf("got calls %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeleteAllJoinsErrors(t *testing.T) {
+	r := &fakeRunner{fn: func(_ int, args []string) ([]byte, error) {
+		switch {
+		case args[1] == "list":
+			return []byte(twoAccounts), nil
+		case args[3] == "alice":
+			return []byte(`{"results":[],"status":4,"error":"Access denied"}`), nil
+		default:
+			return []byte(exampleQuery), nil
+		}
+	}}
+
+	err := (&Client{Runner: r}).DeleteAll("example.com")
+	if !errors.Is(err, ErrorAuthDenied) || !strings.Contains(err.Error(), "alice") {
+		t.Errorf("got %v, want ErrorAuthDenied for alice", err)
+	}
+
+	if n := len(r.commands()); n != 3 {
+		t.Errorf("apw was run %v times, want 3: bob should still be deleted", n)
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+	}{
+		{"plain", `{"results":[],"status":3}`},
+		{"trailing newlines", "{\"results\":[],\"status\":3}\n\n\n"},
+		{"leading warnings", "warning: apw is outdated\nnote: run apw update\n{\"results\":[],\"status\":3}\n"},
+		{"BOM", "\xef\xbb\xbf{\"results\":[],\"status\":3}"},
+		{"BOM and warning", "\xef\xbb\xbfwarning: slow\r\n{\"results\":[],\"status\":3}\r\n"},
+		{"trailing noise", "{\"results\":[],\"status\":3}\ndone.\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var k Query
+			if err := decodeJSON([]byte(tt.out), &k); err != nil {
+				t.Fatal(err)
+			}
+
+			if k.Status != StatusNoResults {
+				t.Errorf("got status %v, want %v", k.Status, StatusNoResults)
+			}
+		})
+	}
+}
+
+func TestDecodeJSONPreview(t *testing.T) {
+	long := "Error: " + strings.Repeat("x", 200) + "\nsecond line"
+	err := decodeJSON([]byte(long), &Query{})
+	if err == nil || !strings.Contains(err.Error(), "Error: xxx") || strings.Contains(err.Error(), "second line") {
+		t.Errorf("got %v, want the start of the first line", err)
+	}
+
+	if strings.Contains(err.Error(), strings.Repeat("x", 129)) {
+		t.Errorf("preview isn't truncated: %v", err)
+	}
+
+	err = decodeJSON([]byte(`{"results":[{"username":"alice","password":"hunter2"`), &Query{})
+	if err == nil || strings.Contains(err.Error(), "hunter2") {
+		t.Errorf("got %v, want an error without the password", err)
+	}
+}
+
+func TestFlagArgsRejected(t *testing.T) {
+	tests := []struct {
+		name string
+		call func(c *Client) error
+		want error
+	}{
+		{"Retrieve", func(c *Client) error {
+			_, err := c.Retrieve("--help")
+			return err
+		}, ErrorDomain},
+		{"Retrieve short flag", func(c *Client) error {
+			_, err := c.Retrieve("-h")
+			return err
+		}, ErrorDomain},
+		{"Retrieve blank", func(c *Client) error {
+			_, err := c.Retrieve(" \t")
+			return err
+		}, ErrorDomain},
+		{"RetrieveAccount domain", func(c *Client) error {
+			_, err := c.RetrieveAccount("--help", "alice")
+			return err
+		}, ErrorDomain},
+		{"RetrieveAccount account", func(c *Client) error {
+			_, err := c.RetrieveAccount("example.com", "--help")
+			return err
+		}, ErrorAccount},
+		{"RetrieveAccount empty account", func(c *Client) error {
+			_, err := c.RetrieveAccount("example.com", "")
+			return err
+		}, ErrorAccount},
+		{"Add", func(c *Client) error {
+			_, err := c.Add("--help", "alice", "s3cret")
+			return err
+		}, ErrorDomain},
+		{"Delete", func(c *Client) error {
+			return c.Delete("example.com", "--help")
+		}, ErrorAccount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &fakeRunner{out: exampleQuery}
+			if err := tt.call(&Client{Runner: r}); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+
+			if got := r.commands(); len(got) > 0 {
+				t.Errorf("apw was run with %q", got)
+			}
+		})
+	}
+}
+
+func TestRetrieveAccountNotFound(t *testing.T) {
+	tests := []struct {
+		name    string
+		out     string
+		domain  string
+		account string
+		want    error
+	}{
+		{"found", exampleQuery, "example.com", "alice", nil},
+		{"no results status", noResults, "example.com", "alice", ErrorDomain},
+		{"empty results", `{"results":[],"status":0}`, "example.com", "alice", ErrorDomain},
+		{"missing account", exampleQuery, "example.com", "bob", ErrorAccount},
+		{"domain stored under another name", exampleQuery, "login.example.com", "bob", ErrorAccount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{Runner: &fakeRunner{out: tt.out}}
+			_, err := c.RetrieveAccount(tt.domain, tt.account)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+
+			if got, want := IsDomainNotFound(err), tt.want == ErrorDomain; got != want {
+				t.Errorf("IsDomainNotFound: got %v, want %v", got, want)
+			}
+
+			if got, want := IsAccountNotFound(err), tt.want == ErrorAccount; got != want {
+				t.Errorf("IsAccountNotFound: got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestLocked(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want bool
+	}{
+		{"invalid session", `{"results":[],"status":9}`, true},
+		{"invalid session with message", `{"results":[],"status":9,"error":"Invalid session"}`, true},
+		{"locked message", `{"results":[],"status":1,"error":"Keychain is locked"}`, true},
+		{"generic error", `{"results":[],"status":1,"error":"Something went wrong"}`, false},
+		{"no results", noResults, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{Runner: &fakeRunner{out: tt.out}}
+			_, err := c.Retrieve("example.com")
+			if err == nil {
+				t.Fatal("got no error")
+			}
+
+			if IsLocked(err) != tt.want {
+				t.Errorf("IsLocked(%v) = %v, want %v", err, !tt.want, tt.want)
+			}
+		})
+	}
+}
+
+func TestDomains(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want []string
+		err  error
+	}{
+		{"empty keychain", noResults, []string{}, nil},
+		{"empty results", `{"results":[],"status":0}`, []string{}, nil},
+		{"sorted and deduplicated", `{"results":[{"domain":"b.com","username":"bob"},
+			{"domain":"a.com","username":"alice"},{"domain":"b.com","username":"carol"}],"status":0}`, []string{"a.com", "b.com"}, nil},
+		{"locked", `{"results":[],"status":9}`, nil, ErrorLocked},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{Runner: &fakeRunner{out: tt.out}}
+			for name, domains := range map[string]func() ([]string, error){"Domains": c.Domains, "ListDomains": c.ListDomains} {
+				d, err := domains()
+				if !errors.Is(err, tt.err) || !slices.Equal(d, tt.want) || (d == nil) != (tt.want == nil) {
+					t.Errorf("%s: got %#v, %v, want %#v, %v", name, d, err, tt.want, tt.err)
+				}
+			}
+
+			if tt.err != nil {
+				return
+			}
+
+			if _, err := c.SearchDomains("a", MatchContains, 0); err != nil {
+				t.Errorf("SearchDomains: %v", err)
+			}
+		})
+	}
+}
+
+func TestRetrieveParentDomainEntries(t *testing.T) {
+	c := &Client{Runner: &fakeRunner{out: twoAccounts}} // Filed under example.com
+
+	accounts, err := c.RetrieveAccounts("login.example.com")
+	if err != nil || len(accounts) != 2 || accounts[0].Username != "alice" || accounts[1].Username != "bob" {
+		t.Errorf("RetrieveAccounts: got %v, %v, want alice and bob", accounts, err)
+	}
+
+	if a, err := c.RetrieveAccount("login.example.com", "bob"); !IsPasswordNotIncluded(err) || a.Username != "bob" {
+		t.Errorf("RetrieveAccount: got %v, %v, want bob", a, err)
+	}
+
+	if _, err := c.RetrieveAccount("login.example.com", "carol"); !IsAccountNotFound(err) {
+		t.Errorf("RetrieveAccount of a missing account: got %v, want ErrorAccount", err)
+	}
+
+	if m, err := c.RetrieveAccountMatches("login.example.com", "alice"); err != nil || len(m) != 1 {
+		t.Errorf("RetrieveAccountMatches: got %v, %v, want alice", m, err)
+	}
+
+	c = &Client{Runner: &fakeRunner{out: `{"results":[],"status":0}`}}
+	if _, err := c.RetrieveAccounts("login.example.com"); !IsDomainNotFound(err) {
+		t.Errorf("RetrieveAccounts without entries: got %v, want ErrorDomain", err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	blocking := runnerFunc(func(ctx context.Context, args ...string) ([]byte, error) {
+		<-ctx.Done() // Like apw waiting on a prompt until it is killed
+		return nil, errors.New("signal: killed")
+	})
+
+	tests := []struct {
+		name    string
+		timeout time.Duration // Client.Timeout
+		opts    []Option
+	}{
+		{"Client.Timeout", 10 * time.Millisecond, nil},
+		{"WithTimeout", 0, []Option{WithTimeout(10 * time.Millisecond)}},
+		{"WithTimeout shorter than Client.Timeout", time.Hour, []Option{WithTimeout(10 * time.Millisecond)}},
+		{"context deadline", 0, []Option{WithContext(deadline(t, 10*time.Millisecond))}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{Runner: blocking, Timeout: tt.timeout}
+			if _, err := c.Retrieve("example.com", tt.opts...); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("got %v, want context.DeadlineExceeded", err)
+			}
+		})
+	}
+}
+
+// deadline returns a context that times out after d, cancelled once t ends.
+func deadline(t *testing.T, d time.Duration) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestNoTimeout(t *testing.T) {
+	r := runnerFunc(func(ctx context.Context, args ...string) ([]byte, error) {
+		if _, ok := ctx.Deadline(); ok {
+			t.Error("apw was run with a deadline")
+		}
+
+		return []byte(exampleQuery), nil
+	})
+
+	if _, err := (&Client{Runner: r}).Retrieve("example.com"); err != nil {
+		t.Fatal(err)
+	}
+}
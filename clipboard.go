@@ -0,0 +1,107 @@
+package keychain
+
+import (
+	"context"
+	"crypto/subtle"
+	"time"
+)
+
+// ClipboardClear is a pending clear of a password copied to the clipboard. It
+// runs in its own goroutine, so it is lost if the process exits first; call
+// Wait before exiting to make sure the password doesn't stay on the clipboard.
+type ClipboardClear struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Wait blocks until the clipboard has been cleared, or the clear was cancelled.
+func (c *ClipboardClear) Wait() {
+	<-c.done
+}
+
+// Cancel stops the clear if it hasn't happened yet, leaving the password on
+// the clipboard, and waits for it to stop.
+func (c *ClipboardClear) Cancel() {
+	c.cancel()
+	c.Wait()
+}
+
+// clearClipboardAfter empties the clipboard after d if it still holds b, unless
+// the returned clear is cancelled first. b is wiped once done.
+func clearClipboardAfter(b []byte, d time.Duration) *ClipboardClear {
+	ctx, cancel := context.WithCancel(context.Background())
+	cc := &ClipboardClear{cancel: cancel, done: make(chan struct{})}
+
+	go func() {
+		defer close(cc.done)
+		defer clear(b)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(d):
+		}
+
+		cur, err := readClipboard(context.Background())
+		defer clear(cur)
+
+		if err == nil && subtle.ConstantTimeCompare(cur, b) == 1 {
+			_ = writeClipboard(context.Background(), nil)
+		}
+	}()
+
+	return cc
+}
+
+// CopyPassword copies the password for account on domain to the macOS
+// clipboard using pbcopy. ctx only bounds the lookup and the copy. If
+// clearAfter > 0, the clipboard is emptied after that long unless it was
+// changed in the meantime, which the returned ClipboardClear can wait for or
+// cancel. Otherwise, the returned ClipboardClear is already done.
+func (c *Client) CopyPassword(ctx context.Context, domain, account string, clearAfter time.Duration) (*ClipboardClear, error) {
+	a, err := c.RetrieveAccountContext(ctx, domain, account)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.copyToClipboard(ctx, clearAfter)
+}
+
+// CopyToClipboard copies the password to the macOS clipboard using pbcopy, see
+// Client.CopyPassword. It returns ErrorPassword or ErrorPasswordNotIncluded
+// when there is no password to copy.
+//
+// The clear after clearAfter doesn't happen if the process exits before then,
+// such as a CLI returning from main, leaving the password on the clipboard.
+// Use CopyToClipboardWithClear and wait for the clear in that case.
+func (k Account) CopyToClipboard(clearAfter time.Duration) error {
+	_, err := k.copyToClipboard(context.Background(), clearAfter)
+	return err
+}
+
+// CopyToClipboardWithClear is like CopyToClipboard, but returns the pending
+// clear so it can be waited for or cancelled.
+func (k Account) CopyToClipboardWithClear(clearAfter time.Duration) (*ClipboardClear, error) {
+	return k.copyToClipboard(context.Background(), clearAfter)
+}
+
+func (k Account) copyToClipboard(ctx context.Context, clearAfter time.Duration) (*ClipboardClear, error) {
+	b, err := k.GetPasswordBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeClipboard(ctx, b); err != nil {
+		clear(b)
+		return nil, err
+	}
+
+	if clearAfter > 0 {
+		return clearClipboardAfter(b, clearAfter), nil
+	}
+
+	clear(b)
+	done := make(chan struct{})
+	close(done)
+	return &ClipboardClear{cancel: func() {}, done: done}, nil
+}
@@ -0,0 +1,59 @@
+package keychain
+
+// Diff is the difference between two Maps, see Map.Diff. Every list is sorted
+// by domain, then username.
+type Diff struct {
+	AddedDomains   []string
+	RemovedDomains []string
+
+	Added   []Result // Accounts only in the newer Map, including those of AddedDomains
+	Removed []Result // Accounts only in the older Map, including those of RemovedDomains
+	Changed []Result // Accounts whose password differs, as in the newer Map
+}
+
+// Diff returns what changed going from k to other. Accounts are matched by
+// domain and username. Passwords are only compared when both were included,
+// so an account isn't Changed when either Map was retrieved without passwords.
+func (k Map) Diff(other Map) Diff {
+	var d Diff
+
+	other.Each(func(domain string, accounts []Account) {
+		if _, ok := k[domain]; !ok {
+			d.AddedDomains = append(d.AddedDomains, domain)
+		}
+
+		for _, a := range accounts {
+			old, ok := k.find(domain, a.Username)
+			if !ok {
+				d.Added = append(d.Added, Result{a, domain})
+			} else if !a.EqualIgnoringUnknown(old) {
+				d.Changed = append(d.Changed, Result{a, domain})
+			}
+		}
+	})
+
+	k.Each(func(domain string, accounts []Account) {
+		if _, ok := other[domain]; !ok {
+			d.RemovedDomains = append(d.RemovedDomains, domain)
+		}
+
+		for _, a := range accounts {
+			if _, ok := other.find(domain, a.Username); !ok {
+				d.Removed = append(d.Removed, Result{a, domain})
+			}
+		}
+	})
+
+	return d
+}
+
+// find returns the first account with username on domain, without checking its password.
+func (k Map) find(domain, username string) (Account, bool) {
+	for _, a := range k[domain] {
+		if a.Username == username {
+			return a, true
+		}
+	}
+
+	return Account{}, false
+}
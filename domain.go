@@ -0,0 +1,174 @@
+package keychain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// ParseDomain reduces a URL such as "https://user@www.Example.com:443/login"
+// to the lowercased bare host apw expects, "example.com". Inputs without a
+// scheme are treated as a host, and IP addresses are kept as is.
+func ParseDomain(input string) (string, error) {
+	s := strings.TrimSpace(input)
+	if !strings.Contains(s, "://") {
+		s = "//" + s
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrorDomain, err)
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if len(host) == 0 {
+		return "", fmt.Errorf("%w: no host in %q", ErrorDomain, input)
+	}
+
+	if net.ParseIP(host) != nil {
+		return host, nil
+	}
+
+	return strings.TrimPrefix(host, "www."), nil
+}
+
+// NormalizeDomain is like ParseDomain, but returns the trimmed input if it can't be parsed.
+func NormalizeDomain(input string) string {
+	d, err := ParseDomain(input)
+	if err != nil {
+		return strings.TrimSpace(input)
+	}
+
+	return d
+}
+
+// RetrieveURL is like Retrieve, but accepts a URL, see ParseDomain.
+func (c *Client) RetrieveURL(rawURL string, opts ...Option) (*Query, error) {
+	domain, err := ParseDomain(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Retrieve(domain, opts...)
+}
+
+// publicSuffixes are common public suffixes with more than one label, under
+// which unrelated parties register domains. Every single label is also one.
+// This is a small subset of the Public Suffix List, to stay dependency-free.
+var publicSuffixes = map[string]bool{
+	"ac.uk": true, "co.uk": true, "gov.uk": true, "ltd.uk": true, "me.uk": true, "net.uk": true, "org.uk": true, "plc.uk": true, "sch.uk": true,
+	"com.au": true, "edu.au": true, "gov.au": true, "net.au": true, "org.au": true,
+	"co.nz": true, "net.nz": true, "org.nz": true,
+	"ac.jp": true, "co.jp": true, "go.jp": true, "ne.jp": true, "or.jp": true,
+	"co.kr": true, "or.kr": true,
+	"com.br": true, "net.br": true, "org.br": true,
+	"com.cn": true, "net.cn": true, "org.cn": true,
+	"co.in": true, "net.in": true, "org.in": true,
+	"co.id": true, "co.il": true, "co.za": true,
+	"com.ar": true, "com.hk": true, "com.mx": true, "com.my": true, "com.ph": true,
+	"com.sg": true, "com.tr": true, "com.tw": true, "com.ua": true, "com.vn": true,
+
+	// Hosting providers giving each user a subdomain
+	"appspot.com": true, "azurewebsites.net": true, "blogspot.com": true, "cloudfront.net": true,
+	"firebaseapp.com": true, "fly.dev": true, "github.io": true, "gitlab.io": true, "herokuapp.com": true,
+	"netlify.app": true, "pages.dev": true, "vercel.app": true, "web.app": true, "workers.dev": true,
+}
+
+// RegistrableDomain returns the part of host that can be registered: its public
+// suffix plus one label, e.g. "example.co.uk" for "login.example.co.uk", or
+// "foo.github.io" for "foo.github.io". IP addresses are returned as is, and an
+// empty string is returned if host is itself a public suffix, such as "co.uk".
+func RegistrableDomain(host string) string {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if net.ParseIP(host) != nil {
+		return host
+	}
+
+	labels := strings.Split(host, ".")
+	suffix := 1
+	for i := len(labels) - 2; i >= 0; i-- {
+		if publicSuffixes[strings.Join(labels[i:], ".")] {
+			suffix = len(labels) - i
+		}
+	}
+
+	if len(labels) <= suffix {
+		return ""
+	}
+
+	return strings.Join(labels[len(labels)-suffix-1:], ".")
+}
+
+// parentDomains returns host followed by each of its parent domains down to
+// its RegistrableDomain, e.g. "login.example.co.uk", "example.co.uk". IP
+// addresses and public suffixes have no parents.
+func parentDomains(host string) []string {
+	d := []string{host}
+	r := RegistrableDomain(host)
+	if len(r) == 0 || net.ParseIP(r) != nil {
+		return d
+	}
+
+	for n := strings.Count(strings.TrimSuffix(host, "."), ".") - strings.Count(r, "."); n > 0; n-- {
+		host = host[strings.IndexByte(host, '.')+1:]
+		d = append(d, host)
+	}
+
+	return d
+}
+
+// GetMatching is like Get, but when host has no matching entry its parent
+// domains are tried in turn, so "login.example.com" can match "example.com".
+// The domain the account was found under is returned alongside it.
+func (k Map) GetMatching(host, account string) (string, *Account, error) {
+	err := error(ErrorDomain)
+	for _, d := range parentDomains(host) {
+		a, dErr := k.Get(d, account)
+		if a != nil {
+			return d, a, dErr
+		}
+
+		if errors.Is(dErr, ErrorAccount) {
+			err = dErr
+		}
+	}
+
+	return "", nil, err
+}
+
+// RetrieveBestMatch returns account for host the way browsers match saved
+// logins: host is normalized as by NormalizeDomain and looked up, then each of
+// its parent domains in turn, from longest to shortest, down to its
+// RegistrableDomain, so "a.github.io" never matches "b.github.io" through
+// "github.io". For "api.login.example.com", that is "api.login.example.com",
+// "login.example.com", then "example.com".
+//
+// The first domain with an entry for account wins. If there is none, the error
+// is ErrorAccount if any of the domains had other entries, else ErrorDomain.
+func (c *Client) RetrieveBestMatch(host, account string) (*Account, error) {
+	return c.RetrieveBestMatchContext(context.Background(), host, account)
+}
+
+func (c *Client) RetrieveBestMatchContext(ctx context.Context, host, account string) (*Account, error) {
+	host = NormalizeDomain(host)
+	if err := validateArgs(host, account); err != nil {
+		return nil, err
+	}
+
+	err := error(ErrorDomain)
+	for _, d := range parentDomains(host) {
+		a, dErr := c.RetrieveAccountContext(ctx, d, account)
+		if a != nil || !IsNotFound(dErr) {
+			return a, dErr
+		}
+
+		if IsAccountNotFound(dErr) {
+			err = dErr
+		}
+	}
+
+	return nil, err
+}
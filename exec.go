@@ -0,0 +1,69 @@
+package keychain
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+)
+
+// run executes apw at r.path. It is only used as the Runner on macOS, but
+// builds everywhere so it can be tested.
+func (r execRunner) run(ctx context.Context, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stdout, stderr := &limitBuffer{max: r.max, exceeded: cancel}, &limitBuffer{max: r.max, exceeded: cancel}
+	cmd := exec.CommandContext(ctx, r.path, args...)
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+
+	err := cmd.Run()
+	if stdout.over || stderr.over {
+		return nil, ErrOutputTooLarge
+	}
+
+	out := stdout.Bytes()
+	if err == nil && len(out) == 0 { // Some output, e.g. the version, may only be printed to stderr
+		out = stderr.Bytes()
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) { // Only JSON is parsed from stdout, diagnostics are kept separately
+		exitErr.Stderr = stderr.Bytes()
+	}
+
+	if errors.Is(err, exec.ErrNotFound) || errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
+		return out, fmt.Errorf("%w: %s", ErrBinaryNotFound, r.path)
+	}
+
+	return out, err
+}
+
+// limitBuffer is a buffer that stops growing past max bytes, discarding the
+// rest of what is written and calling exceeded once. The bytes.Buffer isn't
+// embedded, as io.Copy would then use its ReadFrom and skip the limit.
+type limitBuffer struct {
+	buf      bytes.Buffer
+	max      int
+	exceeded func()
+	over     bool
+}
+
+func (b *limitBuffer) Write(p []byte) (int, error) {
+	if b.max >= 0 && b.buf.Len()+len(p) > b.max {
+		if !b.over {
+			b.over = true
+			b.exceeded() // Kill apw rather than letting it block on a full pipe
+		}
+
+		return len(p), nil
+	}
+
+	return b.buf.Write(p)
+}
+
+func (b *limitBuffer) Bytes() []byte {
+	return b.buf.Bytes()
+}
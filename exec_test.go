@@ -0,0 +1,107 @@
+package keychain
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// scriptRunner returns an execRunner for a shell script standing in for apw.
+func scriptRunner(t *testing.T, script string, max int) execRunner {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+
+	path := filepath.Join(t.TempDir(), "apw")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	return execRunner{path: path, max: max}
+}
+
+func TestExecRunnerStreams(t *testing.T) {
+	tests := []struct {
+		name       string
+		script     string
+		max        int
+		wantOut    string
+		wantStderr string
+		wantErr    error
+	}{
+		{
+			name:    "stderr is not parsed",
+			script:  `echo '{"status":0}'; echo 'warning: something' >&2`,
+			max:     -1,
+			wantOut: "{\"status\":0}\n",
+		},
+		{
+			name:    "stderr only",
+			script:  `echo 'apw 1.0.0' >&2`,
+			max:     -1,
+			wantOut: "apw 1.0.0\n",
+		},
+		{
+			name:       "exit status",
+			script:     `echo 'partial'; echo 'Error: keychain locked' >&2; exit 3`,
+			max:        -1,
+			wantOut:    "partial\n",
+			wantStderr: "Error: keychain locked\n",
+		},
+		{
+			name:    "stdout too large",
+			script:  `echo '{"status":0,"results":[]}'`,
+			max:     8,
+			wantErr: ErrOutputTooLarge,
+		},
+		{
+			name:    "stderr too large",
+			script:  `echo '{}'; echo 'a very long warning' >&2`,
+			max:     8,
+			wantErr: ErrOutputTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := scriptRunner(t, tt.script, tt.max).run(context.Background(), "pw", "list")
+			if string(out) != tt.wantOut {
+				t.Errorf("got output %q, want %q", out, tt.wantOut)
+			}
+
+			var exitErr *exec.ExitError
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("got error %v, want %v", err, tt.wantErr)
+				}
+			case len(tt.wantStderr) > 0:
+				if !errors.As(err, &exitErr) || string(exitErr.Stderr) != tt.wantStderr {
+					t.Errorf("got error %v, want exit error with stderr %q", err, tt.wantStderr)
+				}
+			case err != nil:
+				t.Errorf("unexpected error %v", err)
+			}
+		})
+	}
+}
+
+func TestExecErrorStderr(t *testing.T) {
+	r := scriptRunner(t, `echo 'Error: keychain locked' >&2; exit 1`, -1)
+	c := &Client{Runner: runnerFunc(r.run)}
+
+	_, err := c.Retrieve("example.com")
+	var eErr *ExecError
+	if !errors.As(err, &eErr) || eErr.Stderr != "Error: keychain locked" || eErr.ExitCode != 1 {
+		t.Fatalf("got %v, want ExecError with apw's stderr", err)
+	}
+
+	if !IsLocked(err) {
+		t.Errorf("got %v, want ErrorLocked", err)
+	}
+}
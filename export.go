@@ -0,0 +1,82 @@
+package keychain
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+var csvHeader = []string{"domain", "username", "password"}
+
+// WriteCSV writes every account in k to w as "domain,username,password" rows
+// after a header, sorted by domain and username. This writes plaintext
+// passwords; those apw didn't include are left empty.
+func (k Map) WriteCSV(w io.Writer) error {
+	return k.ExportCSV(w, true)
+}
+
+// ExportCSV is like WriteCSV, but leaves every password empty unless
+// includePasswords is set.
+func (k Map) ExportCSV(w io.Writer, includePasswords bool) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	var err error
+	k.Each(func(domain string, accounts []Account) {
+		for _, a := range accounts {
+			if err != nil {
+				return
+			}
+
+			p := a.Password
+			if p == PasswordNotIncluded || !includePasswords {
+				p = ""
+			}
+
+			err = cw.Write([]string{domain, a.Username, p})
+		}
+	})
+
+	if err != nil {
+		return err
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteQueryCSV writes the results of k to w, see Map.WriteCSV.
+func WriteQueryCSV(w io.Writer, k Query) error {
+	m, err := k.Map()
+	if err != nil {
+		return err
+	}
+
+	return m.WriteCSV(w)
+}
+
+// ExportJSON writes every account in k to w in the same JSON format apw uses,
+// sorted by domain and username, so it can be read back into a Query. Passwords
+// are written in plaintext if includePasswords is set, and as
+// PasswordNotIncluded otherwise.
+func (k Map) ExportJSON(w io.Writer, includePasswords bool) error {
+	var q Query
+	k.Each(func(domain string, accounts []Account) {
+		for _, a := range accounts {
+			if !includePasswords {
+				a.Password = PasswordNotIncluded
+			}
+
+			q.Results = append(q.Results, Result{a, domain})
+		}
+	})
+
+	b, err := q.MarshalWithSecrets()
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
@@ -0,0 +1,95 @@
+package keychain
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	defaultPasswordLength = 20
+
+	charsetLetters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	charsetDigits  = "0123456789"
+	charsetSymbols = "!#$%&()*+,-./:;<=>?@[]^_{}~"
+)
+
+// GenerateOptions configures GeneratePassword. The zero value generates a
+// 20 character password using letters, digits and symbols.
+type GenerateOptions struct {
+	Length    int  // 0 for 20
+	NoDigits  bool // Leave out digits
+	NoSymbols bool // Leave out symbols
+
+	// Charset replaces the characters to choose from, ignoring NoDigits and
+	// NoSymbols. Otherwise, each enabled kind of character is used at least
+	// once when Length allows.
+	Charset string
+}
+
+// GeneratePassword returns a random password drawn with crypto/rand. apw has
+// no way to generate passwords, so this doesn't call it. Passwords never start
+// with "-", which Client.Add rejects, unless Charset has nothing else. The
+// caller should Destroy the result once done with it.
+func GeneratePassword(opts GenerateOptions) (Secret, error) {
+	n := opts.Length
+	if n == 0 {
+		n = defaultPasswordLength
+	}
+
+	if n < 0 {
+		return nil, fmt.Errorf("%sinvalid password length %v", kErr, n)
+	}
+
+	sets := []string{opts.Charset}
+	if len(opts.Charset) == 0 {
+		sets = []string{charsetLetters}
+		if !opts.NoDigits {
+			sets = append(sets, charsetDigits)
+		}
+
+		if !opts.NoSymbols {
+			sets = append(sets, charsetSymbols)
+		}
+	}
+
+	charset := []rune(strings.Join(sets, ""))
+	size := big.NewInt(int64(len(charset)))
+	dashOnly := !slices.ContainsFunc(charset, func(r rune) bool { return r != '-' })
+
+	p := make([]rune, n)
+	for {
+		for i := range p {
+			j, err := rand.Int(rand.Reader, size)
+			if err != nil {
+				return nil, err
+			}
+
+			p[i] = charset[j.Int64()]
+		}
+
+		if (n < len(sets) || containsEach(p, sets)) && (n == 0 || p[0] != '-' || dashOnly) {
+			s := make(Secret, 0, n)
+			for _, r := range p {
+				s = utf8.AppendRune(s, r)
+			}
+
+			clear(p)
+			return s, nil
+		}
+	}
+}
+
+// containsEach reports whether p has at least one character from each of sets.
+func containsEach(p []rune, sets []string) bool {
+	for _, set := range sets {
+		if !slices.ContainsFunc(p, func(r rune) bool { return strings.ContainsRune(set, r) }) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,57 @@
+package keychain
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestGeneratePassword(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    GenerateOptions
+		wantLen int
+		sets    []string // Each must be used
+		without string   // None may be used
+	}{
+		{"default", GenerateOptions{}, defaultPasswordLength, []string{charsetLetters, charsetDigits, charsetSymbols}, ""},
+		{"no symbols", GenerateOptions{Length: 8, NoSymbols: true}, 8, []string{charsetLetters, charsetDigits}, charsetSymbols},
+		{"no digits", GenerateOptions{Length: 8, NoDigits: true}, 8, []string{charsetLetters, charsetSymbols}, charsetDigits},
+		{"charset", GenerateOptions{Length: 5, Charset: "ab€"}, 5, nil, charsetDigits + charsetSymbols},
+		{"dash only", GenerateOptions{Length: 3, Charset: "-"}, 3, nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 50 {
+				s, err := GeneratePassword(tt.opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				p := string(s.Bytes())
+				if n := utf8.RuneCountInString(p); n != tt.wantLen {
+					t.Fatalf("%q: got length %v, want %v", p, n, tt.wantLen)
+				}
+
+				for _, set := range tt.sets {
+					if !strings.ContainsAny(p, set) {
+						t.Errorf("%q: want a character from %q", p, set)
+					}
+				}
+
+				if len(tt.without) > 0 && strings.ContainsAny(p, tt.without) {
+					t.Errorf("%q: want no character from %q", p, tt.without)
+				}
+
+				if strings.HasPrefix(p, "-") && tt.opts.Charset != "-" {
+					t.Errorf("%q: starts with \"-\"", p)
+				}
+			}
+		})
+	}
+
+	if _, err := GeneratePassword(GenerateOptions{Length: -1}); err == nil {
+		t.Error("negative length: want error, got none")
+	}
+}
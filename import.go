@@ -0,0 +1,174 @@
+package keychain
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// csvColumns returns the index of each csvHeader column in header, matching
+// names case-insensitively.
+func csvColumns(header []string) ([]int, error) {
+	cols := make([]int, len(csvHeader))
+	for i, name := range csvHeader {
+		cols[i] = slices.IndexFunc(header, func(h string) bool {
+			return strings.EqualFold(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")), name)
+		})
+
+		if cols[i] < 0 {
+			return nil, fmt.Errorf("%sCSV header %q is missing column %q", kErr, header, name)
+		}
+	}
+
+	return cols, nil
+}
+
+// eachCSVRow calls fn with the line number and fields of each "domain,username,password"
+// row read from r, or the error parsing it, until fn returns false. The first
+// row must be a header naming the columns, in any order and case, otherwise an
+// error is returned before fn is called. Extra columns are ignored. An error
+// reading r stops it and is returned.
+func eachCSVRow(r io.Reader, fn func(line int, domain, username, password string, err error) bool) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return fmt.Errorf("%sreading CSV header: %w", kErr, err)
+	}
+
+	cols, err := csvColumns(header)
+	if err != nil {
+		return err
+	}
+
+	for {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		var pErr *csv.ParseError
+		if errors.As(err, &pErr) { // Only the row is malformed, the rest can still be read
+			if !fn(pErr.Line, "", "", "", err) {
+				return nil
+			}
+
+			continue
+		}
+
+		if err != nil {
+			return fmt.Errorf("%sreading CSV: %w", kErr, err)
+		}
+
+		if !eachCSVField(cr, row, len(header), cols, fn) {
+			return nil
+		}
+	}
+}
+
+// eachCSVField calls fn with the fields of row at cols, or an error if it has fewer than want fields.
+func eachCSVField(cr *csv.Reader, row []string, want int, cols []int, fn func(int, string, string, string, error) bool) bool {
+	line, _ := cr.FieldPos(0)
+	if len(row) < want {
+		return fn(line, "", "", "", fmt.Errorf("%swant %v fields, got %v", kErr, want, len(row)))
+	}
+
+	return fn(line, row[cols[0]], row[cols[1]], row[cols[2]], nil)
+}
+
+// ImportCSV calls Add for each "domain,username,password" row read from r, as
+// written by Map.WriteCSV, see the package-level ImportCSV for the format.
+// Failed rows don't stop the import, their errors are returned along with the
+// number added.
+func (c *Client) ImportCSV(r io.Reader) (added int, errs []error) {
+	return c.ImportCSVContext(context.Background(), r)
+}
+
+// ImportCSVContext is like ImportCSV, but stops once ctx is done, adding its
+// error to those returned.
+func (c *Client) ImportCSVContext(ctx context.Context, r io.Reader) (added int, errs []error) {
+	err := eachCSVRow(r, func(line int, domain, username, password string, err error) bool {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			errs = append(errs, fmt.Errorf("%s%w", kErr, ctxErr))
+			return false
+		}
+
+		if err == nil {
+			_, err = c.AddContext(ctx, domain, username, password)
+		}
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("CSV line %v: %w", line, err))
+		} else {
+			added++
+		}
+
+		return true
+	})
+
+	if err != nil {
+		return added, append(errs, err)
+	}
+
+	return added, errs
+}
+
+// ImportResult lists what ImportCSV did with each row, by CSV line number.
+type ImportResult struct {
+	Added   []int
+	Updated []int         // Rows that already existed, when updating
+	Skipped []int         // Rows that already existed, when not updating
+	Failed  map[int]error // Rows that couldn't be read or stored
+}
+
+// ImportCSV stores each "domain,username,password" row read from r using c,
+// such as those written by Map.ExportCSV. The first row must be a header naming
+// those columns, in any order and case, or nothing is stored. Extra columns are
+// ignored.
+//
+// Rows for accounts that already exist are updated if update is set, and skipped
+// otherwise. Failed rows don't stop the import, but ctx being done does, in which
+// case the result for the rows handled so far is returned along with the error.
+func ImportCSV(ctx context.Context, c *Client, r io.Reader, update bool) (ImportResult, error) {
+	res := ImportResult{Failed: make(map[int]error)}
+
+	var ctxErr error
+	err := eachCSVRow(r, func(line int, domain, username, password string, err error) bool {
+		if err := ctx.Err(); err != nil {
+			ctxErr = fmt.Errorf("%s%w", kErr, err)
+			return false
+		}
+
+		if err == nil {
+			_, err = c.AddContext(ctx, domain, username, password)
+		}
+
+		switch {
+		case err == nil:
+			res.Added = append(res.Added, line)
+		case !errors.Is(err, ErrorDuplicate):
+			res.Failed[line] = err
+		case !update:
+			res.Skipped = append(res.Skipped, line)
+		default:
+			if err := c.UpdateContext(ctx, domain, username, password); err != nil {
+				res.Failed[line] = err
+			} else {
+				res.Updated = append(res.Updated, line)
+			}
+		}
+
+		return true
+	})
+
+	if err != nil {
+		return res, err
+	}
+
+	return res, ctxErr
+}
@@ -0,0 +1,152 @@
+package keychain
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestImportCSV(t *testing.T) {
+	tests := []struct {
+		name  string
+		csv   string
+		added int
+		lines []string // Substrings of each error, in order
+		calls []string
+	}{
+		{
+			name:  "rows",
+			csv:   "domain,username,password\na.com,alice,p1\nb.com,bob,p2\n",
+			added: 2,
+			calls: []string{"pw add a.com alice p1", "pw add b.com bob p2"},
+		},
+		{
+			name:  "bare quote",
+			csv:   "domain,username,password\na.com,alice,p1\nb.com,\"bob\"x,p2\nc.com,carol,p3\n",
+			added: 2,
+			lines: []string{"CSV line 3"},
+			calls: []string{"pw add a.com alice p1", "pw add c.com carol p3"},
+		},
+		{
+			name:  "unterminated quote",
+			csv:   "domain,username,password\n\"unterminated\n",
+			lines: []string{"CSV line 2"},
+		},
+		{
+			name:  "short row",
+			csv:   "domain,username,password\na.com,alice\nb.com,bob,p2\n",
+			added: 1,
+			lines: []string{"CSV line 2: keychain error: want 3 fields, got 2"},
+			calls: []string{"pw add b.com bob p2"},
+		},
+		{
+			name:  "header case and order",
+			csv:   "Password,Domain,Username,Notes\np1,a.com,alice,x\n",
+			added: 1,
+			calls: []string{"pw add a.com alice p1"},
+		},
+		{
+			name:  "header with BOM and spaces",
+			csv:   "\ufeffdomain, username ,password\na.com,alice,p1\n",
+			added: 1,
+			calls: []string{"pw add a.com alice p1"},
+		},
+		{
+			name:  "no header",
+			csv:   "a.com,alice,p1\nb.com,bob,p2\n",
+			lines: []string{`missing column "domain"`},
+		},
+		{
+			name:  "Apple Passwords header",
+			csv:   "Title,URL,Username,Password,Notes,OTPAuth\nGitHub,https://github.com,alice,p1,,\n",
+			lines: []string{`missing column "domain"`},
+		},
+		{
+			name:  "empty",
+			csv:   "",
+			lines: []string{"reading CSV header"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &fakeRunner{out: exampleQuery}
+			added, errs := (&Client{Runner: r}).ImportCSV(strings.NewReader(tt.csv))
+			if added != tt.added {
+				t.Errorf("added %v, want %v", added, tt.added)
+			}
+
+			if len(errs) != len(tt.lines) {
+				t.Fatalf("got errors %v, want %q", errs, tt.lines)
+			}
+
+			for i, err := range errs {
+				if !strings.Contains(err.Error(), tt.lines[i]) {
+					t.Errorf("got error %q, want it to contain %q", err, tt.lines[i])
+				}
+			}
+
+			if got := r.commands(); !slices.Equal(got, tt.calls) {
+				t.Errorf("got calls %q, want %q", got, tt.calls)
+			}
+		})
+	}
+}
+
+func TestImportCSVReadError(t *testing.T) {
+	errRead := errors.New("disk on fire")
+	r := io.MultiReader(strings.NewReader("domain,username,password\na.com,alice,p1\n"), iotest.ErrReader(errRead))
+
+	added, errs := (&Client{Runner: &fakeRunner{out: exampleQuery}}).ImportCSV(r)
+	if added != 1 || len(errs) != 1 || !errors.Is(errs[0], errRead) {
+		t.Errorf("got %v, %v, want 1 added and the read error", added, errs)
+	}
+
+	r = io.MultiReader(strings.NewReader("domain,username,password\n"), iotest.ErrReader(errRead))
+	if _, err := ImportCSV(context.Background(), &Client{Runner: &fakeRunner{out: exampleQuery}}, r, false); !errors.Is(err, errRead) {
+		t.Errorf("package ImportCSV: got %v, want the read error", err)
+	}
+}
+
+func TestImportCSVMalformedRow(t *testing.T) {
+	c := &Client{Runner: &fakeRunner{out: exampleQuery}}
+	res, err := ImportCSV(context.Background(), c, strings.NewReader("domain,username,password\na.com,alice,p1\n\"unterminated\n"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(res.Added, []int{2}) || len(res.Failed) != 1 || res.Failed[3] == nil {
+		t.Errorf("got %+v, want line 2 added and line 3 failed", res)
+	}
+}
+
+func TestImportCSVContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	r := &fakeRunner{out: exampleQuery}
+	added, errs := (&Client{Runner: r}).ImportCSVContext(ctx, strings.NewReader("domain,username,password\na.com,alice,p1\n"))
+	if added != 0 || len(errs) != 1 || !errors.Is(errs[0], context.Canceled) {
+		t.Errorf("got %v, %v, want nothing added and context.Canceled", added, errs)
+	}
+
+	if got := r.commands(); len(got) > 0 {
+		t.Errorf("apw was run with %q", got)
+	}
+}
+
+func TestImportCSVRequiresHeader(t *testing.T) {
+	r := &fakeRunner{out: exampleQuery}
+	res, err := ImportCSV(context.Background(), &Client{Runner: r}, strings.NewReader("Domain,Username\na.com,alice\n"), true)
+	if err == nil || !strings.Contains(err.Error(), `missing column "password"`) {
+		t.Errorf("got %v, want an error for the missing column", err)
+	}
+
+	if len(res.Added)+len(res.Updated)+len(res.Skipped)+len(res.Failed) > 0 || len(r.commands()) > 0 {
+		t.Errorf("rows were imported: %+v, %q", res, r.commands())
+	}
+}
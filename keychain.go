@@ -1,19 +1,27 @@
 package keychain
 
 import (
+	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"os/exec"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"time"
 )
 
 const (
 	PasswordNotIncluded = "Not Included"
+	PasswordRedacted    = "****"
 	kErr                = "keychain error: "
 )
 
 var (
-	PathAPW = "/opt/homebrew/bin/apw"
+	PathAPW          = defaultPathAPW() // Located at init, may be overridden
+	MarshalPasswords = false            // Include plaintext passwords when marshaling an Account to JSON
 )
 
 type Result struct {
@@ -24,6 +32,15 @@ type Result struct {
 type Account struct {
 	Username string `json:"username"`
 	Password string `json:"password"` // "Not Included" when not included
+
+	// Metadata, only present with newer versions of apw.
+	Notes    string     `json:"notes,omitempty"`
+	Created  *time.Time `json:"created,omitempty"`
+	Modified *time.Time `json:"modified,omitempty"`
+	LastUsed *time.Time `json:"lastUsed,omitempty"`
+
+	// Extra holds fields apw returned that aren't modelled above, by name.
+	Extra map[string]json.RawMessage `json:"-"`
 }
 
 type Query struct {
@@ -32,6 +49,35 @@ type Query struct {
 	ResultError string   `json:"error,omitempty"`
 }
 
+// Status codes reported by apw in Query.Status
+const (
+	StatusSuccess = iota
+	StatusGenericError
+	StatusInvalidParam
+	StatusNoResults
+	StatusFailedToDelete
+	StatusFailedToUpdate
+	StatusInvalidMessageFormat
+	StatusDuplicateItem
+	StatusUnknownAction
+	StatusInvalidSession
+)
+
+// QueryError is returned by Query.Error, and unwraps to the Error matching Status.
+type QueryError struct {
+	Status  int
+	Message string
+	Err     Error
+}
+
+func (e *QueryError) Error() string {
+	return Query{Status: e.Status, ResultError: e.Message}.ErrorFmt()
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
 func (k Query) ErrorFmt() string {
 	if k.Status == 0 && len(k.ResultError) == 0 {
 		return kErr + "unknown"
@@ -45,10 +91,46 @@ func (k Query) Error() error {
 		return nil
 	}
 
-	return fmt.Errorf("%s", k.ErrorFmt())
+	return &QueryError{Status: k.Status, Message: k.ResultError, Err: statusToError(k.Status, k.ResultError)}
+}
+
+// statusToError maps an apw status and error message to the matching Error.
+func statusToError(status int, msg string) Error {
+	switch status {
+	case StatusNoResults:
+		return ErrorDomain
+	case StatusInvalidSession: // apw's session expires while the keychain is locked
+		return ErrorLocked
+	case StatusDuplicateItem:
+		return ErrorDuplicate
+	}
+
+	return messageToError(msg)
+}
+
+// messageToError maps an apw error message to the matching Error, for failures
+// apw reports without a distinct status.
+func messageToError(msg string) Error {
+	m := strings.ToLower(msg)
+	switch {
+	case strings.Contains(m, "cancel"): // User dismissed the Touch ID or password prompt
+		return ErrorAuthCancelled
+	case strings.Contains(m, "denied"), strings.Contains(m, "not authorized"):
+		return ErrorAuthDenied
+	case strings.Contains(m, "locked"):
+		return ErrorLocked
+	case strings.Contains(m, "duplicate"), strings.Contains(m, "already exists"):
+		return ErrorDuplicate
+	default:
+		return ErrorDefault
+	}
 }
 
 type Map map[string][]Account
+
+// Error is the kind of a failed lookup or apw response. A QueryError unwraps to
+// the Error matching its status, so errors.Is and errors.As(err, &kind) can be
+// used on anything returned by Retrieve and friends.
 type Error int64
 
 const (
@@ -57,8 +139,61 @@ const (
 	ErrorAccount
 	ErrorPassword
 	ErrorPasswordNotIncluded
+	ErrorNoOTP
+	ErrorAuthDenied
+	ErrorLocked
+	ErrorAuthCancelled
+	ErrorVersion
+	ErrorDuplicate
 )
 
+// RetryableStatus reports whether status is transient, such as the session
+// being invalid while the keychain is still locked after the Mac wakes.
+func RetryableStatus(status int) bool {
+	switch status {
+	case StatusInvalidSession, StatusInvalidMessageFormat:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsRetryable reports whether err is a QueryError with a RetryableStatus.
+func IsRetryable(err error) bool {
+	var qErr *QueryError
+	return errors.As(err, &qErr) && RetryableStatus(qErr.Status) && qErr.Err != ErrorAuthCancelled
+}
+
+// IsNotFound reports whether err is caused by a missing domain or account.
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrorDomain) || errors.Is(err, ErrorAccount)
+}
+
+// IsDomainNotFound reports whether err is caused by there being no entries for
+// a domain, or the domain being invalid.
+func IsDomainNotFound(err error) bool {
+	return errors.Is(err, ErrorDomain)
+}
+
+// IsAccountNotFound reports whether err is caused by a domain having entries,
+// but none for the requested account.
+func IsAccountNotFound(err error) bool {
+	return errors.Is(err, ErrorAccount)
+}
+
+// IsLocked reports whether err is caused by the keychain being locked, so the
+// user can be asked to unlock it before retrying. This is the case for
+// StatusInvalidSession (9), and for any status or failed apw invocation whose
+// error message mentions "locked".
+func IsLocked(err error) bool {
+	return errors.Is(err, ErrorLocked)
+}
+
+// IsPasswordNotIncluded reports whether err is caused by apw omitting the password.
+func IsPasswordNotIncluded(err error) bool {
+	return errors.Is(err, ErrorPasswordNotIncluded)
+}
+
 func (k Error) String() string {
 	return k.Error()
 }
@@ -73,6 +208,18 @@ func (k Error) Error() string {
 		return kErr + "empty password"
 	case errors.Is(k, ErrorPasswordNotIncluded):
 		return kErr + "password not included"
+	case errors.Is(k, ErrorNoOTP):
+		return kErr + "no otp configured"
+	case errors.Is(k, ErrorAuthDenied):
+		return kErr + "authorization denied"
+	case errors.Is(k, ErrorLocked):
+		return kErr + "keychain locked"
+	case errors.Is(k, ErrorAuthCancelled):
+		return kErr + "authorization cancelled"
+	case errors.Is(k, ErrorVersion):
+		return kErr + "unsupported apw version"
+	case errors.Is(k, ErrorDuplicate):
+		return kErr + "account already exists"
 	default:
 		return kErr + "unknown"
 	}
@@ -90,6 +237,234 @@ func (k Account) GetPassword() (string, error) {
 	return k.Password, nil
 }
 
+// GetPasswordBytes is like GetPassword, but returns a copy the caller can wipe
+// after use. Go strings are immutable and can't be scrubbed, so this is the
+// only way to limit how long the plaintext stays in memory.
+func (k Account) GetPasswordBytes() ([]byte, error) {
+	p, err := k.GetPassword()
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(p), nil
+}
+
+// Zero drops the password from k. The previous string may still linger in
+// memory until it is garbage collected, see GetPasswordBytes.
+func (k *Account) Zero() {
+	k.Password = ""
+}
+
+// ZeroAll calls Zero on every account in k.
+func (k Map) ZeroAll() {
+	for _, d := range k {
+		for i := range d {
+			d[i].Zero()
+		}
+	}
+}
+
+// Reveal returns the plaintext password, unlike String and MarshalJSON.
+func (k Account) Reveal() string {
+	return k.Password
+}
+
+// String redacts the password, use GetPassword or Reveal to read it.
+func (k Account) String() string {
+	return fmt.Sprintf("{%s %s}", k.Username, k.redacted().Password)
+}
+
+func (k Account) GoString() string {
+	return fmt.Sprintf("keychain.Account{Username:%q, Password:%q}", k.Username, k.redacted().Password)
+}
+
+func (k Result) String() string {
+	return fmt.Sprintf("{%s %s}", k.Account, k.Domain)
+}
+
+func (k Result) GoString() string {
+	return fmt.Sprintf("keychain.Result{Account:%#v, Domain:%q}", k.Account, k.Domain)
+}
+
+func (k Query) String() string {
+	return fmt.Sprintf("{%v %v %s}", k.Results, k.Status, k.ResultError)
+}
+
+// redacted masks the password, keeping PasswordNotIncluded as it isn't secret.
+func (k Account) redacted() Account {
+	if k.Password != PasswordNotIncluded {
+		k.Password = PasswordRedacted
+	}
+
+	return k
+}
+
+// Equal reports whether k and b have the same username and password. Passwords
+// that weren't included are unknown, so they are never equal to anything.
+func (k Account) Equal(b Account) bool {
+	return k.Password != PasswordNotIncluded && b.Password != PasswordNotIncluded && k.EqualIgnoringUnknown(b)
+}
+
+// EqualIgnoringUnknown is like Equal, but only compares usernames when either
+// password wasn't included.
+func (k Account) EqualIgnoringUnknown(b Account) bool {
+	if k.Username != b.Username {
+		return false
+	}
+
+	if k.Password == PasswordNotIncluded || b.Password == PasswordNotIncluded {
+		return true
+	}
+
+	return subtle.ConstantTimeCompare([]byte(k.Password), []byte(b.Password)) == 1
+}
+
+// Equal is like Account.Equal, but also compares domains.
+func (k Result) Equal(b Result) bool {
+	return k.Domain == b.Domain && k.Account.Equal(b.Account)
+}
+
+// EqualIgnoringUnknown is like Account.EqualIgnoringUnknown, but also compares domains.
+func (k Result) EqualIgnoringUnknown(b Result) bool {
+	return k.Domain == b.Domain && k.Account.EqualIgnoringUnknown(b.Account)
+}
+
+// clone returns a copy of k that shares no memory with it, except for Extra's values.
+func (k Account) clone() Account {
+	k.Extra = maps.Clone(k.Extra)
+	for _, t := range []**time.Time{&k.Created, &k.Modified, &k.LastUsed} {
+		if *t != nil {
+			c := **t
+			*t = &c
+		}
+	}
+
+	return k
+}
+
+// UnmarshalJSON decodes an Account, keeping any fields it doesn't know about in Extra.
+func (k *Account) UnmarshalJSON(b []byte) error {
+	type account Account // Drops methods to avoid recursion
+	var a account
+	if err := json.Unmarshal(b, &a); err != nil {
+		return err
+	}
+
+	extra, err := unknownFields(b, "username", "password", "notes", "created", "modified", "lastUsed")
+	if err != nil {
+		return err
+	}
+
+	a.Extra = extra
+	*k = Account(a)
+	return nil
+}
+
+// UnmarshalJSON is needed as the promoted Account.UnmarshalJSON would drop Domain.
+func (k *Result) UnmarshalJSON(b []byte) error {
+	var d struct {
+		Domain string `json:"domain"`
+	}
+
+	if err := json.Unmarshal(b, &d); err != nil {
+		return err
+	}
+
+	if err := k.Account.UnmarshalJSON(b); err != nil {
+		return err
+	}
+
+	k.Domain = d.Domain
+	if delete(k.Extra, "domain"); len(k.Extra) == 0 {
+		k.Extra = nil
+	}
+
+	return nil
+}
+
+// unknownFields returns the fields of the JSON object b not named in known, or nil if there are none.
+func unknownFields(b []byte, known ...string) (map[string]json.RawMessage, error) {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+
+	for _, name := range known {
+		delete(m, name)
+	}
+
+	if len(m) == 0 {
+		return nil, nil
+	}
+
+	return m, nil
+}
+
+// MarshalJSON masks the password unless MarshalPasswords is set.
+func (k Account) MarshalJSON() ([]byte, error) {
+	type account Account // Drops methods to avoid recursion
+	if !MarshalPasswords {
+		k = k.redacted()
+	}
+
+	return json.Marshal(account(k))
+}
+
+// MarshalJSON is needed as the promoted Account.MarshalJSON would drop Domain.
+func (k Result) MarshalJSON() ([]byte, error) {
+	return k.marshal(MarshalPasswords)
+}
+
+func (k Result) marshal(secrets bool) ([]byte, error) {
+	type account Account
+	type result struct {
+		account
+		Domain string `json:"domain"`
+	}
+
+	if !secrets {
+		k.Account = k.Account.redacted()
+	}
+
+	return json.Marshal(result{account(k.Account), k.Domain})
+}
+
+type secretResult Result
+
+func (k secretResult) MarshalJSON() ([]byte, error) {
+	return Result(k).marshal(true)
+}
+
+// MarshalRedacted marshals k with every password masked, regardless of MarshalPasswords.
+func (k Query) MarshalRedacted() ([]byte, error) {
+	r := make([]Result, len(k.Results))
+	for i, d := range k.Results {
+		r[i] = Result{d.Account.redacted(), d.Domain}
+	}
+
+	k.Results = r
+	return json.Marshal(k)
+}
+
+// MarshalWithSecrets marshals k including plaintext passwords, regardless of MarshalPasswords.
+func (k Query) MarshalWithSecrets() ([]byte, error) {
+	r := make([]secretResult, len(k.Results))
+	for i, d := range k.Results {
+		r[i] = secretResult(d)
+	}
+
+	return json.Marshal(struct {
+		Results     []secretResult `json:"results"`
+		Status      int            `json:"status"`
+		ResultError string         `json:"error,omitempty"`
+	}{r, k.Status, k.ResultError})
+}
+
+// Get returns account on domain, or ErrorDomain or ErrorAccount if missing. When
+// the account exists but has no usable password, it is returned together with
+// ErrorPasswordNotIncluded if apw withheld the password, or ErrorPassword if the
+// stored password is empty. Callers may then re-query with the password included.
+// The returned account is the matched element of k, not a copy of it.
 func (k Map) Get(domain, account string) (*Account, error) {
 	d, ok := k[domain]
 	if !ok {
@@ -97,9 +472,9 @@ func (k Map) Get(domain, account string) (*Account, error) {
 	}
 
 	var a *Account
-	for _, da := range d {
-		if da.Username == account {
-			a = &da
+	for i := range d {
+		if d[i].Username == account {
+			a = &d[i]
 			break
 		}
 	}
@@ -115,6 +490,131 @@ func (k Map) Get(domain, account string) (*Account, error) {
 	return a, nil
 }
 
+// GetFold is like Get, but matches domain and account case-insensitively, the
+// latter as in GetAccountFold. An exact domain is preferred, otherwise domains
+// differing only in case are tried in sorted order. Within a domain, an exact
+// account is preferred, otherwise the first folded match in stored order is used.
+func (k Map) GetFold(domain, account string) (*Account, error) {
+	if _, ok := k[domain]; ok {
+		return k.GetAccountFold(domain, account)
+	}
+
+	err := error(ErrorDomain)
+	for _, d := range k.Domains() {
+		if !strings.EqualFold(d, domain) {
+			continue
+		}
+
+		a, dErr := k.GetAccountFold(d, account)
+		if a != nil {
+			return a, dErr
+		}
+
+		err = dErr
+	}
+
+	return nil, err
+}
+
+// GetAccountFold is like Get, but ignores surrounding whitespace and case in
+// account. An exact match is preferred over one that differs only in case.
+func (k Map) GetAccountFold(domain, account string) (*Account, error) {
+	a, err := k.Get(domain, account)
+	if !errors.Is(err, ErrorAccount) {
+		return a, err
+	}
+
+	d := k[domain]
+	account = strings.TrimSpace(account)
+	for _, match := range []func(string, string) bool{
+		func(a, b string) bool { return a == b },
+		strings.EqualFold,
+	} {
+		for i := range d {
+			if match(strings.TrimSpace(d[i].Username), account) {
+				_, err := d[i].GetPassword()
+				return &d[i], err
+			}
+		}
+	}
+
+	return nil, ErrorAccount
+}
+
+// GetMatches returns every account on domain named account, in stored order.
+// Unlike Get, duplicate entries for the same account are all returned.
+func (k Map) GetMatches(domain, account string) ([]*Account, error) {
+	d, ok := k[domain]
+	if !ok {
+		return nil, ErrorDomain
+	}
+
+	var a []*Account
+	for i := range d {
+		if d[i].Username == account {
+			a = append(a, &d[i])
+		}
+	}
+
+	if len(a) == 0 {
+		return nil, ErrorAccount
+	}
+
+	return a, nil
+}
+
+// GetAll returns a copy of every account on domain, or ErrorDomain if there are none.
+func (k Map) GetAll(domain string) ([]Account, error) {
+	d, ok := k[domain]
+	if !ok {
+		return nil, ErrorDomain
+	}
+
+	return append(make([]Account, 0, len(d)), d...), nil
+}
+
+// Domains returns the domains in k, sorted.
+func (k Map) Domains() []string {
+	d := make([]string, 0, len(k))
+	for domain := range k {
+		d = append(d, domain)
+	}
+
+	sort.Strings(d)
+	return d
+}
+
+// Has reports whether k contains account on domain.
+func (k Map) Has(domain, account string) bool {
+	for _, a := range k[domain] {
+		if a.Username == account {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Count returns the number of accounts across every domain in k.
+func (k Map) Count() int {
+	n := 0
+	for _, d := range k {
+		n += len(d)
+	}
+
+	return n
+}
+
+// Accounts returns the accounts of every domain in k, ordered by domain.
+func (k Map) Accounts() []Account {
+	a := make([]Account, 0)
+	for _, domain := range k.Domains() {
+		a = append(a, k[domain]...)
+	}
+
+	return a
+}
+
 func (k Query) Map() (Map, error) {
 	m := make(map[string][]Account)
 
@@ -135,59 +635,160 @@ func (k Query) Map() (Map, error) {
 	return m, nil
 }
 
-func Retrieve(domain string) (*Query, error) {
-	k, err := callAPW("pw", "get", domain)
-	if err != nil {
-		return nil, err
+// Filter returns a copy of k with only the results pred returns true for.
+func (k Query) Filter(pred func(Result) bool) Query {
+	r := make([]Result, 0)
+	for _, d := range k.Results {
+		if pred(d) {
+			r = append(r, d)
+		}
 	}
 
-	if k == nil {
-		return nil, ErrorDefault
-	}
+	k.Results = r
+	return k
+}
 
-	return k, nil
+// FilterDomain returns a copy of k with only the results for domain.
+func (k Query) FilterDomain(domain string) Query {
+	return k.Filter(func(r Result) bool {
+		return r.Domain == domain
+	})
 }
 
-func RetrieveAccount(domain, account string) (*Account, error) {
-	kq, err := Retrieve(domain)
-	if err != nil {
-		return nil, err
-	}
+// SortByDomain returns a copy of k with results stably sorted by domain, then username.
+func (k Query) SortByDomain() Query {
+	k.Results = append(make([]Result, 0, len(k.Results)), k.Results...)
+	sort.SliceStable(k.Results, func(i, j int) bool {
+		if k.Results[i].Domain != k.Results[j].Domain {
+			return k.Results[i].Domain < k.Results[j].Domain
+		}
 
-	km, err := kq.Map()
-	if err != nil {
-		return nil, err
+		return k.Results[i].Username < k.Results[j].Username
+	})
+
+	return k
+}
+
+// SortedAccounts returns a copy of the accounts on domain, stably sorted by username.
+func (k Map) SortedAccounts(domain string) []Account {
+	a := append(make([]Account, 0, len(k[domain])), k[domain]...)
+	sort.SliceStable(a, func(i, j int) bool {
+		return a[i].Username < a[j].Username
+	})
+
+	return a
+}
+
+// SortedResults returns a copy of the results sorted by domain, then username.
+func (k Query) SortedResults() []Result {
+	return k.SortByDomain().Results
+}
+
+// Len returns the number of results in k.
+func (k Query) Len() int {
+	return len(k.Results)
+}
+
+// IsEmpty reports whether k has no results. apw reports a domain without saved
+// passwords as StatusNoResults, which Error returns as ErrorDomain, so a
+// successful Query is normally only empty after filtering it.
+func (k Query) IsEmpty() bool {
+	return len(k.Results) == 0
+}
+
+// First returns the first result in k, or false if there is none. The result
+// is an element of k.Results, not a copy of it.
+func (k Query) First() (*Result, bool) {
+	if len(k.Results) == 0 {
+		return nil, false
 	}
 
-	ka, err := km.Get(domain, account)
+	return &k.Results[0], true
+}
 
-	return ka, nil
+// Each calls fn with every domain in k and its accounts, both sorted, for deterministic iteration.
+func (k Map) Each(fn func(domain string, accounts []Account)) {
+	for _, domain := range k.Domains() {
+		fn(domain, k.SortedAccounts(domain))
+	}
 }
 
-func RetrieveAccountPassword(domain, account string) (string, error) {
-	ka, err := RetrieveAccount(domain, account)
-	if err != nil {
-		return "", err
+// Clone returns a deep copy of k, which can be modified without affecting k.
+func (k Map) Clone() Map {
+	m := make(Map, len(k))
+	for domain, accounts := range k {
+		m[domain] = make([]Account, len(accounts))
+		for i, a := range accounts {
+			m[domain][i] = a.clone()
+		}
 	}
 
-	return ka.GetPassword()
+	return m
 }
 
-func callAPW(args ...string) (*Query, error) {
-	out, err := exec.Command(PathAPW, args...).CombinedOutput()
-	if err != nil && len(out) == 0 { // Only return error message if we have no stdout
-		return nil, err
+// Filter returns a new Map with only the accounts pred returns true for,
+// leaving out domains without any.
+func (k Map) Filter(pred func(domain string, a Account) bool) Map {
+	m := make(Map)
+	for domain, accounts := range k {
+		for _, a := range accounts {
+			if pred(domain, a) {
+				m[domain] = append(m[domain], a)
+			}
+		}
 	}
 
-	var k Query
-	if err := json.Unmarshal(out, &k); err != nil {
-		return nil, err
+	return m
+}
+
+// Merge returns a copy of k with the accounts of other added. Within a domain,
+// accounts with the same username are deduplicated and the last one wins, so
+// an account in other replaces one with the same username in k, keeping its position.
+func (k Map) Merge(other Map) Map {
+	m := make(Map, len(k))
+	for _, src := range []Map{k, other} {
+		for domain, accounts := range src {
+			for _, a := range accounts {
+				i := slices.IndexFunc(m[domain], func(b Account) bool { return b.Username == a.Username })
+				if i < 0 {
+					m[domain] = append(m[domain], a.clone())
+				} else {
+					m[domain][i] = a.clone()
+				}
+			}
+		}
 	}
 
-	// Check for APW error in response
-	if err := k.Error(); err != nil {
-		return &k, err
+	return m
+}
+
+func Retrieve(domain string, opts ...Option) (*Query, error) {
+	return DefaultClient.Retrieve(domain, opts...)
+}
+
+// RetrieveContext is like Retrieve, but kills apw and returns ctx.Err() once ctx is done.
+func RetrieveContext(ctx context.Context, domain string) (*Query, error) {
+	return DefaultClient.RetrieveContext(ctx, domain)
+}
+
+// ListDomains returns the sorted domains of every stored password, see Client.Domains.
+func ListDomains() ([]string, error) {
+	return DefaultClient.ListDomains()
+}
+
+func RetrieveAccount(domain, account string, opts ...Option) (*Account, error) {
+	return DefaultClient.RetrieveAccount(domain, account, opts...)
+}
+
+func RetrieveAccountContext(ctx context.Context, domain, account string) (*Account, error) {
+	return DefaultClient.RetrieveAccountContext(ctx, domain, account)
+}
+
+func RetrieveAccountPassword(domain, account string) (string, error) {
+	ka, err := RetrieveAccount(domain, account)
+	if err != nil {
+		return "", err
 	}
 
-	return &k, nil
+	return ka.GetPassword()
 }
@@ -0,0 +1,21 @@
+package keychain
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+)
+
+func (r execRunner) Run(ctx context.Context, args ...string) ([]byte, error) {
+	return r.run(ctx, args...)
+}
+
+func writeClipboard(ctx context.Context, b []byte) error {
+	cmd := exec.CommandContext(ctx, "pbcopy")
+	cmd.Stdin = bytes.NewReader(b)
+	return cmd.Run()
+}
+
+func readClipboard(ctx context.Context) ([]byte, error) {
+	return exec.CommandContext(ctx, "pbpaste").Output()
+}
@@ -0,0 +1,19 @@
+//go:build !darwin
+
+package keychain
+
+import (
+	"context"
+)
+
+func (r execRunner) Run(ctx context.Context, args ...string) ([]byte, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func writeClipboard(ctx context.Context, b []byte) error {
+	return ErrUnsupportedPlatform
+}
+
+func readClipboard(ctx context.Context) ([]byte, error) {
+	return nil, ErrUnsupportedPlatform
+}
@@ -0,0 +1,321 @@
+package keychain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMapGetMatchesUsername(t *testing.T) {
+	k := Map{"example.com": {
+		{Username: "alice", Password: "a"},
+		{Username: "bob", Password: "b"},
+		{Username: "carol", Password: "c"},
+	}}
+
+	for _, name := range []string{"alice", "bob", "carol"} {
+		a, err := k.Get("example.com", name)
+		if err != nil {
+			t.Fatalf("Get(%q): %v", name, err)
+		}
+
+		if a.Username != name {
+			t.Errorf("Get(%q) returned %q", name, a.Username)
+		}
+	}
+}
+
+func TestRedactedFormatting(t *testing.T) {
+	const secret = "hunter2-s3cret"
+	a := Account{Username: "alice", Password: secret}
+	r := Result{a, "example.com"}
+	q := Query{Results: []Result{r}}
+
+	values := map[string]any{
+		"Account": a, "*Account": &a,
+		"Result": r, "*Result": &r,
+		"Query": q, "Map": Map{"example.com": {a}},
+		"[]Account": []Account{a},
+	}
+
+	for name, v := range values {
+		for _, verb := range []string{"%v", "%+v", "%#v", "%s"} {
+			if s := fmt.Sprintf(verb, v); strings.Contains(s, secret) {
+				t.Errorf("%s formatted with %s leaks the password: %s", name, verb, s)
+			}
+		}
+
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if strings.Contains(string(b), secret) {
+			t.Errorf("%s marshaled to JSON leaks the password: %s", name, b)
+		}
+	}
+
+	if b, _ := q.MarshalRedacted(); strings.Contains(string(b), secret) {
+		t.Errorf("MarshalRedacted leaks the password: %s", b)
+	}
+
+	if p, err := a.GetPassword(); p != secret || err != nil {
+		t.Errorf("GetPassword: got %q, %v", p, err)
+	}
+}
+
+func TestMapGetAliasesStoredAccount(t *testing.T) {
+	k := Map{"example.com": {{Username: "alice", Password: "old"}, {Username: "bob", Password: "b"}}}
+
+	a, err := k.Get("example.com", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a.Password = "new"
+	if p := k["example.com"][0].Password; p != "new" {
+		t.Errorf("stored password is %q after changing the returned account, want %q", p, "new")
+	}
+
+	if b, _ := k.Get("example.com", "bob"); b.Password != "b" {
+		t.Errorf("other account changed to %q", b.Password)
+	}
+
+	a, _ = k.Clone().Get("example.com", "alice")
+	if a.Password = "cloned"; k["example.com"][0].Password != "new" {
+		t.Error("changing an account returned by a clone changed the original")
+	}
+}
+
+func TestMapHelpers(t *testing.T) {
+	dup := Map{
+		"b.com": {{Username: "alice"}, {Username: "alice"}, {Username: "bob"}},
+		"a.com": {{Username: "alice"}},
+		"c.com": {},
+	}
+
+	tests := []struct {
+		name    string
+		k       Map
+		domains []string
+		count   int
+		has     map[[2]string]bool
+	}{
+		{"nil", nil, []string{}, 0, map[[2]string]bool{{"a.com", "alice"}: false, {"", ""}: false}},
+		{"empty", Map{}, []string{}, 0, map[[2]string]bool{{"a.com", "alice"}: false}},
+		{"duplicate usernames", dup, []string{"a.com", "b.com", "c.com"}, 4, map[[2]string]bool{
+			{"a.com", "alice"}: true,
+			{"b.com", "alice"}: true,
+			{"b.com", "bob"}:   true,
+			{"a.com", "bob"}:   false,
+			{"c.com", "alice"}: false,
+			{"d.com", "alice"}: false,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if d := tt.k.Domains(); !slices.Equal(d, tt.domains) || d == nil {
+				t.Errorf("Domains: got %#v, want %q", d, tt.domains)
+			}
+
+			if n := tt.k.Count(); n != tt.count {
+				t.Errorf("Count: got %v, want %v", n, tt.count)
+			}
+
+			for args, want := range tt.has {
+				if got := tt.k.Has(args[0], args[1]); got != want {
+					t.Errorf("Has(%q, %q): got %v, want %v", args[0], args[1], got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMapMerge(t *testing.T) {
+	k := Map{
+		"a.com": {{Username: "alice", Password: "old"}, {Username: "bob", Password: "b"}},
+		"b.com": {{Username: "carol", Password: "c"}},
+	}
+
+	other := Map{
+		"a.com": {{Username: "dave", Password: "d"}, {Username: "alice", Password: "new"}},
+		"c.com": {{Username: "erin", Password: "e"}, {Username: "erin", Password: "e2"}},
+	}
+
+	want := Map{
+		"a.com": {{Username: "alice", Password: "new"}, {Username: "bob", Password: "b"}, {Username: "dave", Password: "d"}},
+		"b.com": {{Username: "carol", Password: "c"}},
+		"c.com": {{Username: "erin", Password: "e2"}},
+	}
+
+	m := k.Merge(other)
+	if !mapsEqual(m, want) {
+		t.Errorf("got %+v, want %+v", m, want)
+	}
+
+	m["a.com"][0].Password = "changed"
+	if k["a.com"][0].Password != "old" || other["a.com"][1].Password != "new" {
+		t.Error("changing the merged Map changed its inputs")
+	}
+
+	if m := k.Merge(nil); !mapsEqual(m, k) {
+		t.Errorf("merging nil: got %+v, want %+v", m, k)
+	}
+}
+
+func TestMapClone(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	k := Map{"a.com": {{Username: "alice", Password: "a", Created: &created}}}
+
+	c := k.Clone()
+	if !mapsEqual(c, k) {
+		t.Fatalf("got %+v, want %+v", c, k)
+	}
+
+	c["a.com"][0].Password = "changed"
+	*c["a.com"][0].Created = time.Time{}
+	c["a.com"] = append(c["a.com"], Account{Username: "bob"})
+	c["b.com"] = []Account{{Username: "carol"}}
+
+	if a := k["a.com"]; len(k) != 1 || len(a) != 1 || a[0].Password != "a" || !a[0].Created.Equal(created) {
+		t.Errorf("changing the clone changed the original: %+v", k)
+	}
+}
+
+// mapsEqual reports whether a and b hold the same accounts, in the same order.
+func mapsEqual(a, b Map) bool {
+	return maps.EqualFunc(a, b, func(x, y []Account) bool {
+		return slices.EqualFunc(x, y, Account.Equal)
+	})
+}
+
+func TestAccountEqual(t *testing.T) {
+	const ni = PasswordNotIncluded
+	tests := []struct {
+		name          string
+		a, b          Account
+		equal, ignore bool // Equal and EqualIgnoringUnknown
+	}{
+		{"same", Account{Username: "alice", Password: "p"}, Account{Username: "alice", Password: "p"}, true, true},
+		{"different password", Account{Username: "alice", Password: "p"}, Account{Username: "alice", Password: "q"}, false, false},
+		{"different username", Account{Username: "alice", Password: "p"}, Account{Username: "bob", Password: "p"}, false, false},
+		{"one not included", Account{Username: "alice", Password: ni}, Account{Username: "alice", Password: "p"}, false, true},
+		{"other not included", Account{Username: "alice", Password: "p"}, Account{Username: "alice", Password: ni}, false, true},
+		{"both not included", Account{Username: "alice", Password: ni}, Account{Username: "alice", Password: ni}, false, true},
+		{"not included, different username", Account{Username: "alice", Password: ni}, Account{Username: "bob", Password: ni}, false, false},
+		{"sentinel as a prefix", Account{Username: "alice", Password: ni + "!"}, Account{Username: "alice", Password: "p"}, false, false},
+		{"both empty", Account{Username: "alice"}, Account{Username: "alice"}, true, true},
+		{"empty and not included", Account{Username: "alice"}, Account{Username: "alice", Password: ni}, false, true},
+		{"metadata ignored", Account{Username: "alice", Password: "p", Notes: "x"}, Account{Username: "alice", Password: "p"}, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.a.Equal(tt.b); got != tt.equal {
+				t.Errorf("Equal: got %v, want %v", got, tt.equal)
+			}
+
+			if got := tt.a.EqualIgnoringUnknown(tt.b); got != tt.ignore {
+				t.Errorf("EqualIgnoringUnknown: got %v, want %v", got, tt.ignore)
+			}
+
+			ra, rb := Result{tt.a, "example.com"}, Result{tt.b, "example.com"}
+			if ra.Equal(rb) != tt.equal || ra.EqualIgnoringUnknown(rb) != tt.ignore {
+				t.Error("Result comparison differs from Account comparison")
+			}
+
+			rb.Domain = "example.org"
+			if ra.Equal(rb) || ra.EqualIgnoringUnknown(rb) {
+				t.Error("Results on different domains are equal")
+			}
+		})
+	}
+}
+
+func TestGetAccountFold(t *testing.T) {
+	k := Map{
+		"x.com": {
+			{Username: "User@x.com", Password: "upper"},
+			{Username: "user@x.com", Password: "lower"},
+			{Username: " bob@x.com ", Password: "bob"},
+			{Username: "Carol@x.com", Password: "carol"},
+		},
+		"y.com": {
+			{Username: "user@y.com", Password: "lower"},
+			{Username: "User@y.com", Password: "upper"},
+		},
+	}
+
+	tests := []struct {
+		domain, account string
+		want            string // Password of the matched account
+		err             error
+	}{
+		{"x.com", "User@x.com", "upper", nil},
+		{"x.com", "user@x.com", "lower", nil},
+		{"x.com", " user@x.com\t", "lower", nil},
+		{"x.com", "USER@X.COM", "upper", nil},
+		{"x.com", "bob@x.com", "bob", nil},
+		{"x.com", "  BOB@x.com", "bob", nil},
+		{"x.com", "carol@x.com", "carol", nil},
+		{"y.com", "USER@Y.COM", "lower", nil},
+		{"y.com", "User@y.com", "upper", nil},
+		{"x.com", "dave@x.com", "", ErrorAccount},
+		{"X.com", "user@x.com", "", ErrorDomain},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.domain+" "+tt.account, func(t *testing.T) {
+			a, err := k.GetAccountFold(tt.domain, tt.account)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+
+			if tt.err == nil && a.Password != tt.want {
+				t.Errorf("got %q, want %q", a.Password, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetFold(t *testing.T) {
+	k := Map{
+		"Example.com": {{Username: "alice", Password: "Example"}},
+		"example.COM": {{Username: "alice", Password: "COM"}, {Username: "bob", Password: "bob"}},
+		"example.com": {{Username: "carol", Password: "carol"}},
+	}
+
+	tests := []struct {
+		domain, account string
+		want            string // Password of the matched account
+		err             error
+	}{
+		{"example.com", "carol", "carol", nil},
+		{"example.com", "Carol", "carol", nil},
+		{"example.com", "alice", "", ErrorAccount}, // An exact domain isn't folded
+		{"EXAMPLE.COM", "alice", "Example", nil},   // Sorted, so "Example.com" first
+		{"EXAMPLE.COM", "BOB", "bob", nil},
+		{"EXAMPLE.COM", "carol", "carol", nil},
+		{"EXAMPLE.COM", "dave", "", ErrorAccount},
+		{"other.com", "alice", "", ErrorDomain},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.domain+" "+tt.account, func(t *testing.T) {
+			a, err := k.GetFold(tt.domain, tt.account)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+
+			if tt.err == nil && a.Password != tt.want {
+				t.Errorf("got %q, want %q", a.Password, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package keychain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// logArgs returns args with every password replaced by PasswordRedacted.
+func logArgs(args []string) []string {
+	if len(args) > 4 && args[0] == "pw" && (args[1] == "add" || args[1] == "update") {
+		a := append(make([]string, 0, len(args)), args[:4]...)
+		for range args[4:] {
+			a = append(a, PasswordRedacted)
+		}
+
+		return a
+	}
+
+	return args
+}
+
+func (c *Client) logExec(ctx context.Context, args []string, d time.Duration, err error) {
+	if c.Logger == nil {
+		return
+	}
+
+	attrs := []slog.Attr{slog.Any("args", logArgs(args)), slog.Duration("duration", d)}
+
+	var exitErr *ExecError
+	if errors.As(err, &exitErr) {
+		attrs = append(attrs, slog.Int("exit", exitErr.ExitCode))
+	} else if err == nil {
+		attrs = append(attrs, slog.Int("exit", 0))
+	}
+
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+
+	c.Logger.LogAttrs(ctx, slog.LevelDebug, "apw exec", attrs...)
+}
+
+// logParse logs a failure to parse out. Only the kind of error and size of the
+// output are logged, as the error may quote parts of out.
+func (c *Client) logParse(ctx context.Context, args []string, out []byte, err error) {
+	if c.Logger == nil {
+		return
+	}
+
+	c.Logger.LogAttrs(ctx, slog.LevelDebug, "apw parse", slog.Any("args", logArgs(args)),
+		slog.String("error", parseErrorKind(err)), slog.Int("bytes", len(out)))
+}
+
+func parseErrorKind(err error) string {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return "syntax"
+	case errors.As(err, &typeErr):
+		return "type"
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return "truncated"
+	default:
+		return "other"
+	}
+}
+
+// Observer receives the duration and result of each operation a Client runs,
+// e.g. to record metrics. op is the apw subcommand: "get", "list", "add",
+// "update", "delete" or "otp".
+type Observer interface {
+	Observe(op string, d time.Duration, err error)
+}
+
+func (c *Client) observe(op string, start time.Time, err *error) {
+	c.Observer.Observe(op, time.Since(start), *err)
+}
@@ -0,0 +1,50 @@
+package keychain
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteNetrc writes every account in k to w in .netrc format, one machine
+// line per account, sorted by domain and username. Accounts whose password
+// wasn't included are skipped. This writes plaintext passwords.
+//
+// An error is returned for values netrc can't represent, such as those
+// containing whitespace.
+func (k Map) WriteNetrc(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	var err error
+	k.Each(func(domain string, accounts []Account) {
+		for _, a := range accounts {
+			if err != nil {
+				return
+			}
+
+			if a.Password == PasswordNotIncluded {
+				continue
+			}
+
+			for _, v := range []string{domain, a.Username, a.Password} {
+				if len(v) == 0 || strings.ContainsFunc(v, isNetrcSpace) {
+					err = fmt.Errorf("%scan't write %q for %s to netrc: empty or contains whitespace", kErr, a.Username, domain)
+					return
+				}
+			}
+
+			_, err = fmt.Fprintf(bw, "machine %s login %s password %s\n", domain, a.Username, a.Password)
+		}
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+func isNetrcSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r'
+}
@@ -0,0 +1,67 @@
+package keychain
+
+import (
+	"context"
+	"time"
+)
+
+// Option configures a single Retrieve or RetrieveAccount call. Options take
+// precedence over the matching Client fields for that call only.
+type Option func(*callConfig)
+
+type callConfig struct {
+	ctx           context.Context
+	timeout       time.Duration
+	omitPasswords bool
+	binary        string
+}
+
+type callConfigKey struct{}
+
+// WithContext runs the call under ctx instead of context.Background.
+func WithContext(ctx context.Context) Option {
+	return func(cfg *callConfig) {
+		cfg.ctx = ctx
+	}
+}
+
+// WithTimeout overrides Client.Timeout.
+func WithTimeout(d time.Duration) Option {
+	return func(cfg *callConfig) {
+		cfg.timeout = d
+	}
+}
+
+// WithoutPasswords retrieves as if Client.OmitPasswords was set.
+func WithoutPasswords() Option {
+	return func(cfg *callConfig) {
+		cfg.omitPasswords = true
+	}
+}
+
+// WithBinary overrides Client.BinaryPath. It has no effect when Client.Runner is set.
+func WithBinary(path string) Option {
+	return func(cfg *callConfig) {
+		cfg.binary = path
+	}
+}
+
+// applyOptions returns the context to make the call with, carrying the options
+// so they can be read further down by configFrom.
+func applyOptions(opts []Option) context.Context {
+	cfg := callConfig{ctx: context.Background()}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	if len(opts) == 0 {
+		return cfg.ctx
+	}
+
+	return context.WithValue(cfg.ctx, callConfigKey{}, cfg)
+}
+
+func configFrom(ctx context.Context) callConfig {
+	cfg, _ := ctx.Value(callConfigKey{}).(callConfig)
+	return cfg
+}
@@ -0,0 +1,79 @@
+package keychain
+
+import (
+	"context"
+	"time"
+)
+
+type otpResult struct {
+	Domain    string `json:"domain"`
+	Username  string `json:"username"`
+	Code      string `json:"code"`
+	Remaining int    `json:"remaining"` // Seconds left in the current period
+}
+
+type otpQuery struct {
+	Results     []otpResult `json:"results"`
+	Status      int         `json:"status"`
+	ResultError string      `json:"error,omitempty"`
+}
+
+// OTP is a one-time password and how long it remains valid, if apw reports it.
+type OTP struct {
+	Code     string
+	ValidFor time.Duration
+}
+
+// RetrieveOTP returns the current one-time password for account on domain, or
+// ErrorNoOTP if the account has no OTP configured.
+func (c *Client) RetrieveOTP(domain, account string) (*OTP, error) {
+	return c.RetrieveOTPContext(context.Background(), domain, account)
+}
+
+func (c *Client) RetrieveOTPContext(ctx context.Context, domain, account string) (otp *OTP, err error) {
+	if c.Observer != nil {
+		defer c.observe("otp", time.Now(), &err)
+	}
+
+	domain = c.domain(domain)
+	if err := validateArgs(domain, account); err != nil {
+		return nil, err
+	}
+
+	var k otpQuery
+	if _, err := c.call(ctx, &k, "otp", "get", domain); err != nil {
+		return nil, err
+	}
+
+	if err := (Query{Status: k.Status, ResultError: k.ResultError}).Error(); err != nil {
+		return nil, err
+	}
+
+	for _, r := range k.Results {
+		if r.Username == account && len(r.Code) > 0 {
+			return &OTP{Code: r.Code, ValidFor: time.Duration(r.Remaining) * time.Second}, nil
+		}
+	}
+
+	return nil, ErrorNoOTP
+}
+
+// OTP returns the current one-time password code for username on domain.
+func (c *Client) OTP(domain, username string) (string, error) {
+	code, _, err := c.OTPWithExpiry(domain, username)
+	return code, err
+}
+
+// OTPWithExpiry is like OTP, but also returns how long the code remains valid.
+func (c *Client) OTPWithExpiry(domain, username string) (string, time.Duration, error) {
+	return c.OTPWithExpiryContext(context.Background(), domain, username)
+}
+
+func (c *Client) OTPWithExpiryContext(ctx context.Context, domain, username string) (string, time.Duration, error) {
+	otp, err := c.RetrieveOTPContext(ctx, domain, username)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return otp.Code, otp.ValidFor, nil
+}
@@ -0,0 +1,179 @@
+package keychain
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// MatchMode selects how search functions compare strings.
+type MatchMode int
+
+const (
+	MatchExact    MatchMode = iota // Equal strings
+	MatchContains                  // Substring
+	MatchFuzzy                     // Subsequence, e.g. "gthb" matches "github.com"
+	MatchFold                      // Equal ignoring case
+)
+
+func (m MatchMode) match(s, query string) bool {
+	_, ok := m.score(s, query)
+	return ok
+}
+
+// score reports whether s matches query, and how closely, lower being closer.
+func (m MatchMode) score(s, query string) (int, bool) {
+	switch m {
+	case MatchContains:
+		i := strings.Index(s, query)
+		return i, i >= 0
+	case MatchFuzzy:
+		return fuzzyScore(s, query)
+	case MatchFold:
+		return 0, strings.EqualFold(s, query)
+	default:
+		return 0, s == query
+	}
+}
+
+// fuzzyScore matches query as a subsequence of s, scoring by where the match
+// starts plus the number of characters skipped within it.
+func fuzzyScore(s, query string) (int, bool) {
+	q := []rune(query)
+	if len(q) == 0 {
+		return 0, true
+	}
+
+	start, n := -1, 0
+	for i, r := range []rune(s) {
+		if r != q[n] {
+			continue
+		}
+
+		if start < 0 {
+			start = i
+		}
+
+		if n++; n == len(q) {
+			return start + (i - start + 1 - len(q)), true
+		}
+	}
+
+	return 0, false
+}
+
+// FindByUsername returns every account in k whose username matches, along with
+// its domain, ordered by domain.
+func (k Map) FindByUsername(username string, mode MatchMode) []Result {
+	r := make([]Result, 0)
+	for _, domain := range k.Domains() {
+		for _, a := range k[domain] {
+			if mode.match(a.Username, username) {
+				r = append(r, Result{a, domain})
+			}
+		}
+	}
+
+	return r
+}
+
+// SearchUsername returns every stored account whose username matches, see Map.FindByUsername.
+func (c *Client) SearchUsername(username string, mode MatchMode) ([]Result, error) {
+	return c.SearchUsernameContext(context.Background(), username, mode)
+}
+
+func (c *Client) SearchUsernameContext(ctx context.Context, username string, mode MatchMode) ([]Result, error) {
+	km, err := c.RetrieveAllContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return km.FindByUsername(username, mode), nil
+}
+
+// FindByUsername returns every stored account with username, ordered by domain,
+// e.g. to find where a login is reused. Use SearchUsername with MatchFold to
+// ignore case, or another MatchMode for looser matches.
+func (c *Client) FindByUsername(username string) ([]Result, error) {
+	return c.FindByUsernameContext(context.Background(), username)
+}
+
+func (c *Client) FindByUsernameContext(ctx context.Context, username string) ([]Result, error) {
+	return c.SearchUsernameContext(ctx, username, MatchExact)
+}
+
+// SearchDomains returns the stored domains matching query case-insensitively,
+// closest first, for use in autocompletion. A limit > 0 caps the number returned.
+func (c *Client) SearchDomains(query string, mode MatchMode, limit int) ([]string, error) {
+	return c.SearchDomainsContext(context.Background(), query, mode, limit)
+}
+
+func (c *Client) SearchDomainsContext(ctx context.Context, query string, mode MatchMode, limit int) ([]string, error) {
+	domains, err := c.DomainsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	type match struct {
+		domain string
+		score  int
+	}
+
+	query = strings.ToLower(query)
+	m := make([]match, 0)
+	for _, d := range domains {
+		if score, ok := mode.score(strings.ToLower(d), query); ok {
+			m = append(m, match{d, score})
+		}
+	}
+
+	sort.SliceStable(m, func(i, j int) bool { // Domains are already sorted by name
+		if m[i].score != m[j].score {
+			return m[i].score < m[j].score
+		}
+
+		return len(m[i].domain) < len(m[j].domain)
+	})
+
+	if limit > 0 && len(m) > limit {
+		m = m[:limit]
+	}
+
+	d := make([]string, len(m))
+	for i := range m {
+		d[i] = m[i].domain
+	}
+
+	return d, nil
+}
+
+// SearchDomainsRegexp returns every stored account whose domain matches pattern,
+// ordered by domain, then username. An invalid pattern returns the error from
+// regexp.Compile.
+func (c *Client) SearchDomainsRegexp(pattern string) ([]Result, error) {
+	return c.SearchDomainsRegexpContext(context.Background(), pattern)
+}
+
+func (c *Client) SearchDomainsRegexpContext(ctx context.Context, pattern string) ([]Result, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	km, err := c.RetrieveAllContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r := make([]Result, 0)
+	km.Each(func(domain string, accounts []Account) {
+		if re.MatchString(domain) {
+			for _, a := range accounts {
+				r = append(r, Result{a, domain})
+			}
+		}
+	})
+
+	return r, nil
+}
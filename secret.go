@@ -0,0 +1,40 @@
+package keychain
+
+import "encoding/json"
+
+// Secret holds a password in a byte slice that can be wiped after use, unlike
+// a string. Formatting or marshalling a Secret never reveals its contents.
+type Secret []byte
+
+func (s Secret) String() string {
+	return PasswordRedacted
+}
+
+func (s Secret) GoString() string {
+	return "keychain.Secret(" + PasswordRedacted + ")"
+}
+
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(PasswordRedacted), nil
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(PasswordRedacted)
+}
+
+// Bytes returns the plaintext, which is invalidated by Destroy.
+func (s Secret) Bytes() []byte {
+	return s
+}
+
+// Destroy overwrites the plaintext with zeroes.
+func (s Secret) Destroy() {
+	clear(s)
+}
+
+// GetPasswordSecret is like GetPassword, but returns a Secret the caller
+// should Destroy once done with it.
+func (k Account) GetPasswordSecret() (Secret, error) {
+	b, err := k.GetPasswordBytes()
+	return Secret(b), err
+}
@@ -0,0 +1,39 @@
+package keychain
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSecretRedacted(t *testing.T) {
+	const plain = "hunter2"
+	s := Secret(plain)
+
+	j, err := json.Marshal(map[string]Secret{"password": s})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, out := range map[string]string{
+		"%v":   fmt.Sprintf("%v", s),
+		"%s":   fmt.Sprintf("%s", s),
+		"%#v":  fmt.Sprintf("%#v", s),
+		"%+v":  fmt.Sprintf("%+v", struct{ S Secret }{s}),
+		"json": string(j),
+	} {
+		if strings.Contains(out, plain) || !strings.Contains(out, PasswordRedacted) {
+			t.Errorf("%s: got %q", name, out)
+		}
+	}
+
+	if string(s.Bytes()) != plain {
+		t.Errorf("Bytes: got %q, want %q", s.Bytes(), plain)
+	}
+
+	s.Destroy()
+	if strings.Trim(string(s), "\x00") != "" {
+		t.Errorf("Destroy: got %q", s)
+	}
+}
@@ -0,0 +1,110 @@
+package keychain
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// StreamAll is like RetrieveAll, but decodes the results one at a time and
+// calls fn with each, without building a Map of every account. It stops and
+// returns the error from fn as soon as fn fails. Results are passed in the
+// order apw printed them. Each apw call's output is still held in memory, so
+// this saves on the decoded results, not on apw's output.
+//
+// The status apw reports is checked before fn is first called, so fn only
+// sees the results of a successful listing. Failures are retried as for any
+// other call, see Client.Retries.
+func (c *Client) StreamAll(ctx context.Context, fn func(Result) error) (err error) {
+	if c.Observer != nil {
+		defer c.observe("list", time.Now(), &err)
+	}
+
+	var out []byte
+	err = c.retry(ctx, func() error {
+		var status struct { // Results are skipped until the status is known
+			Status      int    `json:"status"`
+			ResultError string `json:"error"`
+		}
+
+		o, err := c.call(ctx, &status, "pw", "list")
+		if err != nil {
+			return err
+		}
+
+		out = o
+		return Query{Status: status.Status, ResultError: status.ResultError}.Error()
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return streamResults(out, fn)
+}
+
+// streamResults finds the results of the JSON object in out like decodeJSON,
+// calling fn with each of them. out must have been decoded successfully before.
+func streamResults(out []byte, fn func(Result) error) error {
+	b := bytes.TrimPrefix(out, []byte("\xef\xbb\xbf"))
+	if i := bytes.IndexByte(b, '{'); i > 0 {
+		b = b[i:]
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if _, err := dec.Token(); err != nil { // Opening '{'
+		return fmt.Errorf("%sinvalid apw output: %w", kErr, err)
+	}
+
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("%sinvalid apw output: %w", kErr, err)
+		}
+
+		if t == "results" {
+			return streamArray(dec, fn)
+		}
+
+		if err := dec.Decode(&json.RawMessage{}); err != nil {
+			return fmt.Errorf("%sinvalid apw output: %w", kErr, err)
+		}
+	}
+
+	return nil
+}
+
+// streamArray decodes the next JSON array in dec, which may be null, calling fn with each element.
+func streamArray(dec *json.Decoder, fn func(Result) error) error {
+	t, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("%sinvalid apw output: %w", kErr, err)
+	}
+
+	if t == nil {
+		return nil
+	}
+
+	if t != json.Delim('[') {
+		return fmt.Errorf("%sinvalid apw output: results is %v, not an array", kErr, t)
+	}
+
+	for dec.More() {
+		var r Result
+		if err := dec.Decode(&r); err != nil {
+			return fmt.Errorf("%sinvalid apw output: %w", kErr, err)
+		}
+
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+
+	if _, err := dec.Token(); err != nil { // Closing ']'
+		return fmt.Errorf("%sinvalid apw output: %w", kErr, err)
+	}
+
+	return nil
+}
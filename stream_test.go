@@ -0,0 +1,97 @@
+package keychain
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestStreamAll(t *testing.T) {
+	const (
+		alice = `{"domain":"a.com","username":"alice","password":"Not Included"}`
+		bob   = `{"domain":"b.com","username":"bob","password":"Not Included"}`
+	)
+
+	tests := []struct {
+		name  string
+		out   string
+		want  []string // Usernames passed to fn, in order
+		err   error    // Wanted error, nil for any error when fails is set
+		fails bool
+	}{
+		{"results before status", `{"results":[` + bob + `,` + alice + `],"status":0}`, []string{"bob", "alice"}, nil, false},
+		{"results after status", `{"status":0,"results":[` + alice + `,` + bob + `]}`, []string{"alice", "bob"}, nil, false},
+		{"other fields", `{"status":0,"extra":{"results":1},"results":[` + alice + `],"error":""}`, []string{"alice"}, nil, false},
+		{"null results", `{"results":null,"status":0}`, nil, nil, false},
+		{"no results", `{"status":0}`, nil, nil, false},
+		{"leading warnings", "warning: apw is outdated\nnote: update\n{\"results\":[" + alice + "],\"status\":0}\n", []string{"alice"}, nil, false},
+		{"non-array results", `{"status":0,"results":{"domain":"a.com"}}`, nil, nil, true},
+		{"locked", `{"results":[` + alice + `],"status":9}`, nil, ErrorLocked, true},
+		{"error status", `{"results":[` + alice + `],"status":1,"error":"Access denied"}`, nil, ErrorAuthDenied, true},
+		{"truncated", `{"status":0,"results":[` + alice, nil, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			c := &Client{Runner: &fakeRunner{out: tt.out}}
+			err := c.StreamAll(context.Background(), func(r Result) error {
+				got = append(got, r.Username)
+				return nil
+			})
+
+			if (err != nil) != tt.fails || (tt.err != nil && !errors.Is(err, tt.err)) {
+				t.Errorf("got error %v, want %v (fails %v)", err, tt.err, tt.fails)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("fn got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamAllStopsOnError(t *testing.T) {
+	errStop := errors.New("stop")
+	c := &Client{Runner: &fakeRunner{out: `{"status":0,"results":[
+		{"domain":"a.com","username":"alice"},{"domain":"b.com","username":"bob"},{"domain":"c.com","username":"carol"}]}`}}
+
+	var got []string
+	err := c.StreamAll(context.Background(), func(r Result) error {
+		got = append(got, r.Username)
+		if r.Username == "bob" {
+			return errStop
+		}
+
+		return nil
+	})
+
+	if !errors.Is(err, errStop) || !slices.Equal(got, []string{"alice", "bob"}) {
+		t.Errorf("got %q, %v, want alice and bob, then errStop", got, err)
+	}
+}
+
+func TestStreamAllRetries(t *testing.T) {
+	r := &fakeRunner{fn: func(call int, _ []string) ([]byte, error) {
+		if call == 1 {
+			return []byte(`{"results":[{"domain":"a.com","username":"stale"}],"status":9}`), nil
+		}
+
+		return []byte(`{"results":[{"domain":"a.com","username":"alice"}],"status":0}`), nil
+	}}
+
+	var got []string
+	err := (&Client{Runner: r, Retries: 1}).StreamAll(context.Background(), func(r Result) error {
+		got = append(got, r.Username)
+		return nil
+	})
+
+	if err != nil || !slices.Equal(got, []string{"alice"}) {
+		t.Errorf("got %q, %v, want only the retried results", got, err)
+	}
+
+	if n := len(r.commands()); n != 2 {
+		t.Errorf("apw was run %v times, want 2", n)
+	}
+}
@@ -0,0 +1,102 @@
+package keychain
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+)
+
+// AuthTransport is an http.RoundTripper setting Basic Auth on each request
+// from the keychain entry for the request's host. Only https requests get
+// credentials unless AllowInsecure is set.
+type AuthTransport struct {
+	Client   *Client           // nil uses DefaultClient
+	Base     http.RoundTripper // nil uses http.DefaultTransport
+	Username string            // Account to use, empty for the host's first account
+
+	// Strict fails the request when no password is found, instead of sending
+	// it unmodified.
+	Strict bool
+
+	// AllowInsecure sends credentials over plain http too, where anyone on the
+	// network can read them.
+	AllowInsecure bool
+}
+
+func (t *AuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	a, err := t.account(req)
+	if err == nil {
+		authed := req.Clone(req.Context()) // RoundTrip must not modify the caller's request
+		if err = a.SetBasicAuth(authed); err == nil {
+			return base.RoundTrip(authed)
+		}
+	}
+
+	if t.Strict {
+		if req.Body != nil { // RoundTrip must close the body, even on errors
+			req.Body.Close()
+		}
+
+		return nil, fmt.Errorf("%sno credentials for %s: %w", kErr, req.URL.Hostname(), err)
+	}
+
+	return base.RoundTrip(req)
+}
+
+// BasicAuth returns the value of an Authorization header for Basic Auth with k,
+// or ErrorPasswordNotIncluded if its password wasn't fetched.
+func (k Account) BasicAuth() (string, error) {
+	p, err := k.GetPassword()
+	if err != nil {
+		return "", err
+	}
+
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(k.Username+":"+p)), nil
+}
+
+// SetBasicAuth sets req to use Basic Auth with k, see BasicAuth.
+func (k Account) SetBasicAuth(req *http.Request) error {
+	p, err := k.GetPassword()
+	if err != nil {
+		return err
+	}
+
+	req.SetBasicAuth(k.Username, p)
+	return nil
+}
+
+func (t *AuthTransport) account(req *http.Request) (*Account, error) {
+	if req.URL.Scheme != "https" && !t.AllowInsecure {
+		return nil, fmt.Errorf("%srefusing to send credentials over %s", kErr, req.URL.Scheme)
+	}
+
+	c := t.Client
+	if c == nil {
+		c = DefaultClient
+	}
+
+	ctx, domain := req.Context(), NormalizeDomain(req.URL.Hostname())
+	if len(t.Username) > 0 {
+		return c.RetrieveAccountContext(ctx, domain, t.Username)
+	}
+
+	accounts, err := c.RetrieveAccountsContext(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(accounts) == 0 {
+		return nil, ErrorAccount
+	}
+
+	if _, err := accounts[0].GetPassword(); err != nil {
+		return nil, err
+	}
+
+	return &accounts[0], nil
+}
@@ -0,0 +1,100 @@
+package keychain
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestAuthTransport(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, p, ok := r.BasicAuth()
+		fmt.Fprintf(w, "%v %s %s", ok, u, p)
+	})
+
+	tlsSrv := httptest.NewTLSServer(handler)
+	defer tlsSrv.Close()
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	tests := []struct {
+		name      string
+		url       string
+		transport AuthTransport
+		want      string
+		wantErr   bool
+		wantCalls int
+	}{
+		{"https", tlsSrv.URL, AuthTransport{}, "true alice s3cret", false, 1},
+		{"https username", tlsSrv.URL, AuthTransport{Username: "bob"}, "true bob hunter2", false, 1},
+		{"https missing username", tlsSrv.URL, AuthTransport{Username: "eve"}, "false  ", false, 1},
+		{"https missing username strict", tlsSrv.URL, AuthTransport{Username: "eve", Strict: true}, "", true, 1},
+		{"http", srv.URL, AuthTransport{}, "false  ", false, 0},
+		{"http strict", srv.URL, AuthTransport{Strict: true}, "", true, 0},
+		{"http allowed", srv.URL, AuthTransport{AllowInsecure: true}, "true alice s3cret", false, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &fakeRunner{out: `{"results":[
+				{"domain":"127.0.0.1","username":"alice","password":"s3cret"},
+				{"domain":"127.0.0.1","username":"bob","password":"hunter2"}],"status":0}`}
+
+			tr := tt.transport
+			tr.Client = &Client{Runner: r}
+			tr.Base = tlsSrv.Client().Transport
+
+			body := &closeRecorder{Reader: strings.NewReader("data")}
+			req, err := http.NewRequest(http.MethodPost, tt.url, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := (&http.Client{Transport: &tr}).Do(req)
+			if tt.wantErr {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatal("want error, got none")
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				defer resp.Body.Close()
+				b, err := io.ReadAll(resp.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if got := string(b); got != tt.want {
+					t.Errorf("got %q, want %q", got, tt.want)
+				}
+			}
+
+			if got := len(r.commands()); got != tt.wantCalls {
+				t.Errorf("got %v apw calls, want %v", got, tt.wantCalls)
+			}
+
+			if !body.closed.Load() {
+				t.Error("request body wasn't closed")
+			}
+		})
+	}
+}
+
+// closeRecorder is a request body recording whether it was closed.
+type closeRecorder struct {
+	io.Reader
+	closed atomic.Bool
+}
+
+func (b *closeRecorder) Close() error {
+	b.closed.Store(true)
+	return nil
+}
@@ -0,0 +1,75 @@
+package keychain
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+var versionRegexp = regexp.MustCompile(`(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// parseVersion finds the first "major.minor[.patch]" version in s.
+func parseVersion(s string) (v [3]int, ok bool) {
+	m := versionRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return v, false
+	}
+
+	for i, n := range m[1:] {
+		v[i], _ = strconv.Atoi(n) // Missing patch is 0
+	}
+
+	return v, true
+}
+
+// Version returns the semantic version reported by "apw --version", e.g. "1.2.3".
+func (c *Client) Version() (string, error) {
+	return c.VersionContext(context.Background())
+}
+
+func (c *Client) VersionContext(ctx context.Context) (string, error) {
+	out, err := c.Exec(ctx, "--version")
+	if err != nil {
+		return "", err
+	}
+
+	v := versionRegexp.FindString(string(out)) // Either "1.2.3" or "apw v1.2.3"
+	if len(v) == 0 {
+		return "", fmt.Errorf("%w: unrecognized version %q", ErrorVersion, out)
+	}
+
+	return v, nil
+}
+
+// RequireVersion returns ErrorVersion if apw is older than minVersion, e.g. "1.2.0".
+func (c *Client) RequireVersion(minVersion string) error {
+	want, ok := parseVersion(minVersion)
+	if !ok {
+		return fmt.Errorf("%w: invalid minimum version %q", ErrorVersion, minVersion)
+	}
+
+	v, err := c.Version()
+	if err != nil {
+		return err
+	}
+
+	have, _ := parseVersion(v)
+	for i := range have {
+		if have[i] != want[i] {
+			if have[i] < want[i] {
+				return fmt.Errorf("%w: apw %s is older than %s", ErrorVersion, v, minVersion)
+			}
+
+			break
+		}
+	}
+
+	return nil
+}
+
+// IsAvailable reports whether apw can be run, i.e. it exists and reports its version.
+func (c *Client) IsAvailable() bool {
+	_, err := c.Version()
+	return err == nil
+}